@@ -0,0 +1,129 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"os"
+)
+
+type (
+	CleanupMode uint8
+)
+
+const (
+	NoCleanup CleanupMode = iota
+	CleanEmpty
+	CleanTemp
+
+	TmpExt = ".partdec-tmp"
+)
+
+func (fp FilePath) In(dir string) string {
+
+	if fp.Temp {
+		return newRelativePath(fp.Base, dir) + TmpExt
+	}
+	return newRelativePath(fp.Base, dir)
+
+}
+
+func (fio *FileIO) Commit() error {
+
+	var err error
+	for _, m := range fio.Members() {
+		err = JoinErr(err, m.commit())
+	}
+	return err
+
+}
+
+func (fio *FileIO) commit() error {
+
+	if !fio.Path.Temp || fio.PullState() != Completed {
+		return nil
+	}
+
+	if err := fio.Close(); err != nil {
+		return err
+	}
+
+	fp := fio.PullPath()
+	fp.Temp = false
+	final := fp.In(fp.DstDir)
+
+	if err := os.Rename(fp.Relative, final); err != nil {
+		return err
+	}
+
+	fp.Relative = final
+	fio.PushPath(fp)
+	return nil
+
+}
+
+func (fios FileIOs) Cleanup(mode CleanupMode) {
+
+	if mode == NoCleanup {
+		return
+	}
+
+	for _, fio := range fios.All() {
+
+		fio.Close()
+		fp := fio.PullPath()
+
+		size, _ := fio.DataSize()
+		if size == 0 || (mode == CleanTemp && fp.Temp) {
+			os.Remove(fp.Relative)
+		}
+
+	}
+
+}
+
+func (cm *CleanupMode) String() string {
+
+	switch *cm {
+	case CleanEmpty:
+		return "empty"
+	case CleanTemp:
+		return "temp"
+	default:
+		return "none"
+	}
+
+}
+
+func (cm *CleanupMode) Type() string {
+	return "CleanupMode"
+}
+
+func (cm *CleanupMode) Set(value string) error {
+
+	switch value {
+	case "none":
+		*cm = NoCleanup
+	case "empty":
+		*cm = CleanEmpty
+	case "temp":
+		*cm = CleanTemp
+	default:
+		return ErrParse
+	}
+	return nil
+
+}
@@ -0,0 +1,126 @@
+package partdec
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCommit(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	fio, err := newPartFileIO("part", "test", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer fio.Close()
+
+	if fio.Path.Relative != "test/part"+TmpExt {
+		t.Fatalf("got path %s, want the temp name", fio.Path.Relative)
+	}
+	fio.Write([]byte("data"))
+
+	if err := fio.Commit(); err != nil || !IsFile("test/part"+TmpExt) {
+		t.Fatalf("got %v: an incomplete part is renamed", err)
+	}
+
+	fio.PushState(Completed)
+	if err := fio.Commit(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fio.Path.Relative != "test/part" || fio.Path.Temp {
+		t.Errorf("got path %s, temp %t, want test/part, false", fio.Path.Relative, fio.Path.Temp)
+	}
+	if IsFile("test/part" + TmpExt) {
+		t.Errorf("the temp file is left behind")
+	}
+	if b, _ := os.ReadFile("test/part"); string(b) != "data" {
+		t.Errorf("got %q, want %q", b, "data")
+	}
+
+}
+
+func TestResumeTemp(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	os.WriteFile("test/part"+TmpExt, []byte("data"), FilePerm)
+
+	fio, err := newPartFileIO("part", "test", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer fio.Close()
+
+	if fio.Path.Relative != "test/part"+TmpExt || !fio.Path.Temp {
+		t.Errorf("got path %s: a run without atomic writes starts over", fio.Path.Relative)
+	}
+	if size, _ := fio.DataSize(); size != 4 {
+		t.Errorf("got size %d, want 4", size)
+	}
+
+	os.WriteFile("test/final", []byte("data"), FilePerm)
+	os.WriteFile("test/final"+TmpExt, []byte("da"), FilePerm)
+
+	final, err := newPartFileIO("final", "test", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer final.Close()
+
+	if final.Path.Relative != "test/final" || final.Path.Temp {
+		t.Errorf("got path %s: a committed part is fetched again", final.Path.Relative)
+	}
+
+}
+
+func TestCleanup(t *testing.T) {
+
+	cases := []struct {
+		mode CleanupMode
+		left []string
+	}{
+		{NoCleanup, []string{"empty", "data", "temp" + TmpExt}},
+		{CleanEmpty, []string{"data", "temp" + TmpExt}},
+		{CleanTemp, []string{"data"}},
+	}
+
+	for _, c := range cases {
+
+		os.MkdirAll("test/", 0750)
+
+		var fios FileIOs
+		for _, base := range []string{"empty", "data", "temp"} {
+			fio, err := newPartFileIO(base, "test", base == "temp")
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if base != "empty" {
+				fio.Write([]byte("data"))
+			}
+			fios = append(fios, fio)
+		}
+
+		fios.Cleanup(c.mode)
+
+		for _, name := range []string{"empty", "data", "temp" + TmpExt} {
+			want := false
+			for _, l := range c.left {
+				want = want || l == name
+			}
+			if IsFile("test/"+name) != want {
+				t.Errorf("%s: got %s left %t, want %t", c.mode.String(), name, !want, want)
+			}
+		}
+
+		for _, fio := range fios {
+			fio.Close()
+		}
+		os.RemoveAll("test/")
+
+	}
+
+}
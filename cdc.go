@@ -0,0 +1,156 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bufio"
+	"io"
+	"math/bits"
+	"strings"
+)
+
+type (
+	CDCParams struct {
+		Min, Avg, Max int64
+	}
+)
+
+var (
+	gear = newGearTable(0x9e3779b97f4a7c15) //must not change, or all boundaries move
+)
+
+func newGearTable(seed uint64) (t [256]uint64) {
+
+	for i := range t {
+		seed += 0x9e3779b97f4a7c15 //splitmix64
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return t
+
+}
+
+func cdcMask(n int) uint64 {
+
+	n = min(max(n, 1), 63)
+	return ((1 << n) - 1) << (64 - n) //the high bits mix the most bytes
+
+}
+
+func SplitByContent(dataSize int64, cp CDCParams, p *prober) ([]ByteRange, error) {
+
+	if dataSize <= cp.Min {
+		return SplitByteRange(dataSize, 1, UnknownSize), nil
+	}
+
+	r, err := p.open(0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	br := bufio.NewReaderSize(r, probeSize)
+
+	avgBits := bits.Len64(uint64(cp.Avg)) - 1
+	maskS := cdcMask(avgBits + 1) //normalized chunking
+	maskL := cdcMask(avgBits - 1)
+
+	var brs []ByteRange
+	var start, pos int64
+	var hash uint64
+
+	for {
+
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		pos++
+
+		n := pos - start
+		if n <= cp.Min {
+			continue //cut-point skipping
+		}
+
+		hash = (hash << 1) + gear[b]
+
+		mask := maskL
+		if n < cp.Avg {
+			mask = maskS
+		}
+
+		if hash&mask == 0 || n >= cp.Max {
+			brs = append(brs, ByteRange{Start: start, End: pos - 1})
+			start = pos
+			hash = 0
+		}
+
+	}
+
+	if start < dataSize {
+		brs = append(brs, ByteRange{Start: start, End: dataSize - 1})
+	}
+
+	return brs, nil
+
+}
+
+func (cp *CDCParams) String() string {
+
+	if cp.Avg == 0 {
+		return ""
+	}
+	return toEIC(cp.Min) + "," + toEIC(cp.Avg) + "," + toEIC(cp.Max)
+
+}
+
+func (cp *CDCParams) Type() string {
+	return "CDCParams"
+}
+
+func (cp *CDCParams) Set(value string) error {
+
+	var sizes []int64
+	for _, v := range strings.Split(value, ",") {
+		var bs byteSize
+		if err := bs.Set(strings.TrimSpace(v)); err != nil || bs < 1 {
+			return ErrParse
+		}
+		sizes = append(sizes, int64(bs))
+	}
+
+	switch len(sizes) {
+	case 1:
+		*cp = CDCParams{Min: sizes[0] / 4, Avg: sizes[0], Max: sizes[0] * 4}
+	case 3:
+		*cp = CDCParams{Min: sizes[0], Avg: sizes[1], Max: sizes[2]}
+	default:
+		return ErrParse
+	}
+
+	if !(cp.Min <= cp.Avg && cp.Avg <= cp.Max) {
+		return ErrParse
+	}
+
+	return nil
+
+}
@@ -33,7 +33,19 @@ func main() {
 		os.Exit(1)
 	}
 
+	if opt.Shard != nil {
+		if err = partdec.RunShard(opt); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	d, err = partdec.NewDownload(opt)
+	if partdec.IsErr(err, partdec.ErrNotModified) {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		return
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
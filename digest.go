@@ -0,0 +1,193 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+type (
+	Digest struct {
+		Algo   string
+		Sum    []byte
+		Header string
+		h      hash.Hash
+	}
+)
+
+const (
+	WantReprDigest = "sha-256=10, sha-512=5"
+)
+
+var (
+	digestRank = []string{"sha-512", "sha-256", "md5"} //strongest first
+)
+
+func ParseDigest(hdr http.Header) *Digest {
+
+	found := make(map[string]*Digest)
+
+	for _, v := range hdr.Values("Repr-Digest") { //RFC 9530 dictionary, values as :base64:
+		for _, member := range strings.Split(v, ",") {
+			k, sf, ok := strings.Cut(strings.TrimSpace(member), "=")
+			sf, _, _ = strings.Cut(sf, ";")
+			if !ok || len(sf) < 2 || sf[0] != ':' || sf[len(sf)-1] != ':' {
+				continue
+			}
+			addDigest(found, strings.ToLower(k), sf[1:len(sf)-1], "Repr-Digest")
+		}
+	}
+
+	for _, v := range hdr.Values("Digest") { //RFC 3230
+		for _, member := range strings.Split(v, ",") {
+			k, b64, ok := strings.Cut(strings.TrimSpace(member), "=")
+			if ok {
+				addDigest(found, strings.ToLower(k), b64, "Digest")
+			}
+		}
+	}
+
+	if v := hdr.Get("Content-MD5"); v != "" {
+		addDigest(found, "md5", v, "Content-MD5")
+	}
+
+	for _, algo := range digestRank {
+		if dg, ok := found[algo]; ok {
+			return dg
+		}
+	}
+	return nil
+
+}
+
+func addDigest(found map[string]*Digest, algo, b64, header string) {
+
+	if _, ok := found[algo]; ok {
+		return //the first header wins
+	}
+
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+	if err != nil {
+		return
+	}
+
+	dg := &Digest{Algo: algo, Sum: sum, Header: header}
+	if h := dg.New(); h == nil || h.Size() != len(sum) {
+		return
+	}
+	found[algo] = dg
+
+}
+
+func (dg *Digest) New() hash.Hash {
+
+	switch dg.Algo {
+	case "sha-512":
+		return sha512.New()
+	case "sha-256":
+		return sha256.New()
+	case "md5":
+		return md5.New()
+	}
+	return nil
+
+}
+
+func (d *Download) VerifyOutput() error {
+
+	if d.Digest == nil && d.Sig == nil {
+		return nil
+	}
+
+	if d.Stream == nil { //a stream is hashed on the way out
+		ws, err := d.outputWriters()
+		if err != nil {
+			return err
+		}
+		w := io.MultiWriter(ws...)
+		for _, fio := range d.Files {
+			lead := fio.Lead()
+			if err := hashFile(w, lead.PullPath().Relative, lead.headSize()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if dg := d.Digest; dg != nil {
+		if !bytes.Equal(dg.h.Sum(nil), dg.Sum) {
+			return NewErr("%s: %s from %s", ErrDigest, dg.Algo, dg.Header)
+		}
+		fmt.Fprintf(Stderr, "verified: %s from %s\n", dg.Algo, dg.Header)
+	}
+
+	if sig := d.Sig; sig != nil {
+		err := sig.chk.Verify()
+		sig.chk = nil
+		if err != nil {
+			return NewErr("%s: %s: %s", ErrSig, sig.Format, err)
+		}
+		fmt.Fprintf(Stderr, "verified: %s signature\n", sig.Format)
+	}
+
+	return nil
+
+}
+
+func (d *Download) outputWriters() ([]io.Writer, error) {
+
+	var ws []io.Writer
+	if d.Digest != nil {
+		d.Digest.h = d.Digest.New()
+		ws = append(ws, d.Digest.h)
+	}
+	if d.Sig != nil {
+		chk, err := d.Sig.start()
+		if err != nil {
+			return nil, err
+		}
+		d.Sig.chk = chk
+		ws = append(ws, chk)
+	}
+	return ws, nil
+
+}
+
+func hashFile(w io.Writer, path string, skip int64) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(skip, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+
+}
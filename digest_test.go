@@ -0,0 +1,48 @@
+package partdec
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+)
+
+func TestParseDigest(t *testing.T) {
+
+	data := []byte("hello world")
+	s256 := sha256.Sum256(data)
+	m5 := md5.Sum(data)
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		hdr    http.Header
+		algo   string
+		header string
+	}{
+		{http.Header{"Repr-Digest": {"sha-256=:" + b64(s256[:]) + ":"}}, "sha-256", "Repr-Digest"},
+		{http.Header{"Digest": {"MD5=" + b64(m5[:]) + ", SHA-256=" + b64(s256[:])}}, "sha-256", "Digest"},
+		{http.Header{"Content-Md5": {b64(m5[:])}, "Repr-Digest": {"unixsum=:AAAA:"}}, "md5", "Content-MD5"},
+		{http.Header{"Repr-Digest": {"sha-256=:AAAA:"}}, "", ""}, //wrong length
+	}
+
+	for i, tt := range tests {
+		dg := ParseDigest(tt.hdr)
+		if tt.algo == "" {
+			if dg != nil {
+				t.Errorf("%d: got %s, want none", i, dg.Algo)
+			}
+			continue
+		}
+		if dg == nil || dg.Algo != tt.algo || dg.Header != tt.header {
+			t.Errorf("%d: got %+v, want %s from %s", i, dg, tt.algo, tt.header)
+			continue
+		}
+		h := dg.New()
+		h.Write(data)
+		if string(h.Sum(nil)) != string(dg.Sum) {
+			t.Errorf("%d: sum mismatch", i)
+		}
+	}
+
+}
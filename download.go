@@ -17,12 +17,14 @@ limitations under the License.
 package partdec
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"time"
 )
 
@@ -43,37 +45,88 @@ type (
 	DLType uint8
 
 	DLOptions struct {
-		URI       string
-		BasePath  string
-		DstDirs   []string
-		PartCount int
-		PartSize  int64
-		ReDL      FileResets
-		UI        func(*Download)
-		Force     bool
-		Mod       *IOMod
+		URI          string
+		BasePath     string
+		DstDirs      []string
+		DirWeights   []float64
+		DirQuotas    []int64
+		AutoWeight   bool
+		Layout       Layout
+		PartMap      PartMap
+		PartCount    int
+		NameTemplate string
+		PartSize     int64
+		Align        int64
+		RecordSize   int64
+		Range        *RangeSpec
+		Ranges       []RangeSpec
+		Only         []int
+		Shard        *Shard
+		Repair       bool
+		NoVerify     bool
+		Pieces       string
+		NoDigest     bool
+		VerifySig    string
+		PubKey       string
+		Timestamping bool
+		Xattr        bool
+		Delimiter    []byte
+		CSV          bool
+		CDC          CDCParams
+		ReDL         FileResets
+		UI           func(*Download)
+		Force        bool
+		Output       string
+		Window       int
+		Prealloc     bool
+		Failover     bool
+		Replicas     int
+		Atomic       bool
+		Cleanup      CleanupMode
+		Fsync        FsyncPolicy
+		Mod          *IOMod
+		origin       int64
+		parts        []ByteRange
+		srcSize      int64
 	}
 
 	Download struct {
-		Files     FileIOs
-		Sources   []DataCaster
-		URI       string
-		DataSize  int64
-		Type      DLType
-		UI        func(*Download)
-		Resumable bool
-		Mod       *IOMod
-		Flow      *FlowControl
-		Stop      context.CancelFunc
-		Ctx       context.Context
+		Files        FileIOs
+		Sources      []DataCaster
+		URI          string
+		DataSize     int64
+		Type         DLType
+		UI           func(*Download)
+		Resumable    bool
+		Mod          *IOMod
+		Stream       *Stream
+		Manifest     *Manifest
+		Failover     *Failover
+		Cleanup      CleanupMode
+		Fsync        FsyncPolicy
+		Repair       bool
+		NoVerify     bool
+		Prealloc     bool
+		Pieces       *PieceHashes
+		Digest       *Digest
+		Sig          *Signature
+		Validator    Validator
+		Timestamping bool
+		Xattr        bool
+		Shard        *Shard
+		Leases       []*Lease
+		Flow         *FlowControl
+		Stop         context.CancelFunc
+		Ctx          context.Context
 	}
 
 	endpoint struct {
 		c   context.Context
 		dc  DataCaster
 		fio *FileIO
+		fo  *Failover
 		r   io.ReadCloser
-		w   io.WriteCloser
+		w   *replicaWriter
 	}
 )
 
@@ -96,15 +149,68 @@ func (d *Download) Start() (err error) {
 	}
 
 	partCount := len(d.Files)
-	errCh := make(chan error, partCount)
+	errCount := partCount
+
+	if d.Stream != nil {
+		defer d.Stream.Close()
+		errCount++
+	}
+
+	if d.Sig != nil {
+		defer d.Sig.Close()
+	}
+
+	errCh := make(chan error, errCount)
+
+	if d.Manifest != nil {
+		defer d.SaveManifest()
+		d.Flow.WG.Add(1)
+		go d.trackManifest()
+	}
+
+	if d.Fsync.Mode == FsyncInterval {
+		d.Flow.WG.Add(1)
+		go d.syncLoop()
+	}
+
+	if d.Leases != nil {
+		for _, l := range d.Leases {
+			l.ctx, l.stop = context.WithCancel(d.Ctx) //stops the part alone if the lease is lost
+		}
+		defer d.releaseLeases()
+		d.Flow.WG.Add(1)
+		go d.trackLeases()
+	}
 
 	d.Flow.WG.Add(1)
 	go d.fetchAll(errCh)
 
-	err = catchErr(errCh, partCount)
+	if d.Stream != nil {
+		d.Flow.WG.Add(1)
+		go d.streamAll(errCh)
+	}
+
+	err = catchErr(errCh, errCount)
 	d.Stop()
 
 	d.Flow.WG.Wait()
+
+	if err == nil && d.Stream == nil {
+		err = d.VerifySpanningPieces() //pieces across part boundaries
+	}
+
+	if err == nil {
+		err = d.VerifyOutput()
+	}
+
+	if err == nil {
+		d.Stamp()
+	}
+
+	if err != nil {
+		d.Files.Cleanup(d.Cleanup)
+	}
+
 	return err
 
 }
@@ -115,7 +221,15 @@ func (d *Download) fetchAll(errCh chan error) {
 
 	gendc := d.DataCasterGenerator()
 
-	for _, fio := range d.Files {
+	for i, fio := range d.Files {
+
+		if d.Stream != nil {
+			if err := d.Stream.Window.AcquireCtx(d.Ctx); err != nil {
+				errCh <- err
+				return
+			}
+		}
+
 		dc, err := gendc()
 
 		if err != nil {
@@ -123,17 +237,23 @@ func (d *Download) fetchAll(errCh chan error) {
 			return
 		}
 
-		if fio.State == Completed || fio.State == Broken {
+		if ls := fio.Lead().State; ls == Completed || ls == Broken {
 			dc.Close()
-			fio.Close()
+			for _, m := range fio.Members() {
+				m.Close()
+			}
 			errCh <- nil
 			continue
 		}
 
 		d.Flow.Acquire()
 		d.Flow.WG.Add(1)
+		ctx := d.Ctx
+		if i < len(d.Leases) {
+			ctx = d.Leases[i].ctx
+		}
 		go d.fetch(
-			&endpoint{c: d.Ctx, dc: dc, fio: fio},
+			&endpoint{c: ctx, dc: dc, fio: fio, fo: d.Failover},
 			errCh,
 		)
 	}
@@ -146,39 +266,76 @@ func (d *Download) fetch(e *endpoint, errCh chan<- error) {
 	defer d.Flow.Release()
 	defer e.dc.Close()
 
-	if err := e.fio.Open(); err != nil {
-		e.fio.PushState(Broken)
-		errCh <- err
-		return
+	rw := &replicaWriter{}
+	for _, m := range e.fio.Healthy() {
+		if err := m.Open(); err != nil {
+			m.PushState(Broken)
+			continue
+		}
+		if err := m.SeekOffset(); err != nil {
+			m.Close()
+			m.PushState(Broken)
+			continue
+		}
+		rw.fios = append(rw.fios, m)
 	}
-	defer e.fio.Close()
 
-	if _, err := e.fio.Seek(0, io.SeekEnd); err != nil {
-		e.fio.PushState(Broken)
-		errCh <- err
+	if len(rw.fios) == 0 {
+		errCh <- NewErr("%s: %s", ErrBroken, e.fio.Path.Relative)
 		return
 	}
+	defer rw.Close()
+
+	rw.pv = newPieceVerifier(d.Pieces, rw.Lead())
 
+	e.w = rw
+	go e.watch() //once, as repairs below copy again
 	err := e.copyWithRetry(d.Mod.Retry)
+	for t := 0; err != nil && d.Repair && d.Resumable && t < repairAttempts; t++ {
+		if e.c.Err() != nil || d.repairWriter(rw) != nil {
+			break
+		}
+		if rw.Lead().PullState() == Completed {
+			err = nil
+			break
+		}
+		err = e.copyWithRetry(d.Mod.Retry) //retries an I/O error break within the run
+	}
 	if err != nil {
 		if !IsErr(err, context.Canceled) {
-			e.fio.PushState(Broken)
+			rw.PushState(Broken)
+		}
+		if e.c.Err() != nil && d.Ctx.Err() == nil {
+			err = nil //the lease was lost, so another shard finishes the part
 		}
 		errCh <- err
 		return
 	}
 
-	e.fio.PushState(Completed)
-	errCh <- nil
+	if rw.pv != nil && len(rw.pv.bad) > 0 {
+		if err = d.refetchPieces(rw, rw.pv.bad); err != nil {
+			rw.PushState(Broken)
+			errCh <- err
+			return
+		}
+	}
+
+	rw.PushState(Completed)
+	rw.Close()
+	errCh <- d.settle(e.fio)
 
 }
 
-func (d *Download) InitFiles(partSize int64, fr FileResets) (err error) {
+func (d *Download) InitFiles(brs []ByteRange, fr FileResets) (err error) {
 
-	if err := d.Files.SetByteRange(d.DataSize, partSize); err != nil {
+	if err := d.Files.SetByteRange(brs); err != nil {
 		return err
 	}
 
+	if d.Manifest != nil {
+		d.Files.ApplyManifest(d.Manifest)
+	}
+
 	switch {
 	case !d.Resumable:
 		for _, fio := range d.Files {
@@ -191,10 +348,24 @@ func (d *Download) InitFiles(partSize int64, fr FileResets) (err error) {
 		}
 	}
 
+	if d.Repair && d.Resumable {
+		d.RepairBroken()
+	}
+
+	if !d.NoVerify && d.Resumable && !fr[Resume] { //no use checking what is reset next
+		d.VerifyResume()
+	}
+
 	if err = d.Files.RenewByState(fr); err != nil {
 		return err
 	}
 
+	for _, fio := range d.Files {
+		if err = d.settle(fio); err != nil {
+			fmt.Fprintf(Stderr, "%s\n", err)
+		}
+	}
+
 	return nil
 
 }
@@ -214,22 +385,292 @@ func NewDownload(opt *DLOptions) (d *Download, err error) {
 		return nil, err
 	}
 
-	fios, err := BuildFileIOs(opt.PartCount, opt.BasePath, opt.DstDirs)
+	if opt.VerifySig != "" {
+		if d.Sig, err = LoadSignature(opt.VerifySig, opt.PubKey, opt.URI); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				d.Sig.Close()
+			}
+		}()
+	}
+
+	if opt.Timestamping && opt.Output != "" && stamped([]string{opt.Output}, d.Validator.Modified) {
+		return nil, NewErr("%w: %s", ErrNotModified, opt.Output)
+	}
+
+	var st *Stream
+	if opt.Output != "" {
+		if st, err = NewStream(opt.Output, opt.Window); err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				st.Close()
+			}
+		}()
+		opt.BasePath = filepath.Base(opt.BasePath)
+		opt.DstDirs = []string{st.TmpDir}
+		opt.DirWeights, opt.DirQuotas, opt.AutoWeight = nil, nil, false
+		opt.PartMap, opt.Replicas = nil, 0
+		opt.Atomic, opt.Cleanup = false, NoCleanup
+		opt.CSV = false //parts must join back to the source
+		if !d.Resumable && opt.Mod != nil {
+			opt.Mod.Retry = 0 //a retry truncates bytes already streamed
+		}
+	}
+
+	if opt.PartMap != nil {
+		opt.DstDirs = opt.PartMap.Dirs()
+	}
+
+	dds, err := NewDstDirs(opt.DstDirs, opt.DirWeights, opt.DirQuotas)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.AutoWeight {
+		if err = AutoWeight(dds); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = checkReplicas(dds, opt.Replicas); err != nil {
+		return nil, err
+	}
+
+	d.Shard = opt.Shard
+
+	if d.Resumable && st == nil {
+		mp := ManifestPath(opt.BasePath, opt.DstDirs)
+		switch {
+		case d.Shard != nil:
+			mp = d.Shard.ManifestPath(mp)
+		case opt.Only == nil:
+			if mp, err = LocateManifest(opt.BasePath, opt.DstDirs); err != nil {
+				return nil, err
+			}
+		}
+		if d.Manifest, err = LoadManifest(mp); err != nil {
+			return nil, err
+		}
+		d.Manifest.keep = d.Shard != nil
+	}
+
+	unit := AlignUnit(opt.Align, opt.RecordSize)
+	brs := SplitAlignedByteRange(d.DataSize, opt.PartCount, opt.PartSize, unit)
+	if d.DataSize > 0 {
+		brs = AlignByteRange(brs, opt.origin, unit) //on source offsets, as a slice may start anywhere
+	}
+
+	var header []byte
+	p := newProber(d.Type, opt.URI, d.DataSize)
+	p.base = opt.origin
+
+	grown, grew, err := opt.grow(d, p)
 	if err != nil {
 		return nil, err
 	}
 
+	switch {
+	case grown != nil:
+		brs = grown
+	case opt.CDC.Avg > 0 && d.DataSize > 0 && d.Resumable:
+		if brs, err = SplitByContent(d.DataSize, opt.CDC, p); err != nil {
+			return nil, err
+		}
+	case opt.CSV:
+		if brs, header, err = AlignToCSV(brs, p); err != nil {
+			return nil, err
+		}
+	case opt.Delimiter != nil:
+		if brs, err = AlignToDelimiter(brs, opt.Delimiter, p); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case opt.parts != nil:
+		brs = opt.parts
+	default:
+		ShiftByteRange(brs, opt.origin)
+	}
+
+	if len(brs) > PartSoftLimit && !opt.Force {
+		return nil, NewErr("%s of %d: %d", ErrPartLimit, PartSoftLimit, len(brs))
+	}
+
+	var dp DirPlan
+	switch {
+	case opt.PartMap != nil:
+		dp, err = opt.PartMap.Plan(len(brs))
+	default:
+		dp, err = PlanDirs(brs, dds, opt.Layout)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.AutoWeight || opt.DirWeights != nil || len(opt.DirQuotas) > 0 ||
+		opt.Layout != Contiguous || opt.PartMap != nil {
+		dp.Explain(Stderr, brs, dds)
+	}
+
+	name, err := NewFileNamer(opt.BasePath, opt.NameTemplate, brs)
+	if err != nil {
+		return nil, err
+	}
+
+	if grew {
+		if err = d.Manifest.rename(partPaths(dp, name), brs); err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.Timestamping && st == nil && !grew {
+		if err = opt.checkTimestamp(d, partPaths(dp, name), brs); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Pieces, err = opt.loadPieces(d.Manifest); err != nil {
+		return nil, err
+	}
+
+	if d.Manifest != nil && d.Shard == nil && opt.Only == nil && header == nil && !opt.ReDL.isSet() {
+		same := d.Manifest.DataSize == d.DataSize && d.Manifest.URI == opt.URI //the same source as before
+		if len(d.Manifest.Parts) == 0 && opt.origin == 0 && opt.parts == nil {
+			tmpl := cmp.Or(d.Manifest.Template, opt.NameTemplate) //the names of the earlier run
+			size := cmp.Or(d.Manifest.DataSize, d.DataSize)
+			if d.Manifest.Parts, err = ScanSiblings(opt.BasePath, tmpl, dp, size); err != nil {
+				return nil, err
+			}
+			if d.Manifest.Parts, err = p.checkSiblings(d.Manifest.Parts); err != nil {
+				return nil, err
+			}
+			same = true
+		}
+		paths := partPaths(dp, name)
+		if same {
+			if err = d.Manifest.Repartition(p, paths, brs, opt.Atomic); err != nil {
+				return nil, err
+			}
+		}
+		if err = d.Manifest.Rebalance(dp, paths, brs, opt.DstDirs); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.Manifest != nil {
+		d.Manifest.Template = opt.NameTemplate
+	}
+
+	if opt.Shard != nil {
+		if !d.Resumable {
+			return nil, NewErr("%s: %s", ErrSelect, ErrMultPart)
+		}
+		if opt.Only, d.Leases, err = opt.Shard.Claim(partPaths(dp, name)); err != nil {
+			return nil, err
+		}
+		if len(opt.Only) == 0 {
+			return nil, ErrNoClaim
+		}
+		d.Shard.total = d.DataSize
+		defer func() {
+			if err != nil {
+				d.releaseLeases()
+			}
+		}()
+	}
+
+	var sel []int
+	if opt.Only != nil {
+		if !d.Resumable {
+			return nil, NewErr("%s: %s", ErrSelect, ErrMultPart)
+		}
+		if sel, dp, brs, name, err = SelectParts(opt.Only, dp, brs, name); err != nil {
+			return nil, err
+		}
+		d.DataSize = 0
+		for _, br := range brs {
+			d.DataSize += br.End - br.Start + 1 //progress covers the chosen parts only
+		}
+	}
+
+	fios, err := BuildFileIOs(dp, name, opt.Atomic)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			fios.Cleanup(opt.Cleanup)
+		}
+	}()
+
+	if err = fios.BuildReplicas(dds, opt.Replicas); err != nil {
+		return nil, err
+	}
+
+	first := 1
+	if len(sel) > 0 && sel[0] > 0 {
+		first = 0 //the first part, which holds the header, is not selected
+	}
+	if len(fios) > first {
+		fios[first:].SetHeader(header)
+	}
+
 	d.Files = fios
+	d.Fsync = opt.Fsync
+	d.URI = opt.URI
+	d.Repair = opt.Repair && st == nil //the output cannot take back bytes already written
+	d.Prealloc = opt.Prealloc
+	d.NoVerify = opt.NoVerify
+	d.Timestamping = opt.Timestamping
+	d.Xattr = opt.Xattr
 
-	if err = d.InitFiles(opt.PartSize, opt.ReDL); err != nil {
+	if opt.NoDigest || len(header) > 0 || opt.Range != nil || opt.Ranges != nil ||
+		opt.Only != nil || opt.Shard != nil {
+		d.Digest = nil //the output is not the whole representation
+	}
+
+	if err = d.InitFiles(brs, opt.ReDL); err != nil {
 		return nil, err
 	}
 
+	if st == nil && !opt.Force {
+		if err = d.Files.CheckFreeSpace(); err != nil {
+			return nil, err
+		}
+	}
+
+	if opt.Prealloc && st == nil {
+		switch err = d.Files.Preallocate(); {
+		case IsErr(err, ErrPrealloc):
+			fmt.Fprintf(Stderr, "%s\n", err)
+			err = nil
+		case err != nil:
+			return nil, err
+		}
+	}
+
 	d.Sources = make([]DataCaster, 2*MaxConcurrentFetch) //ring buffer
-	d.URI = opt.URI
 	d.UI = opt.UI
 	d.Flow = NewFlowControl(MaxConcurrentFetch)
 	d.Mod = opt.Mod
+	d.Stream = st
+	if st != nil && (d.Digest != nil || d.Sig != nil) {
+		ws, err := d.outputWriters()
+		if err != nil {
+			return nil, err
+		}
+		st.tee = io.MultiWriter(ws...)
+	}
+	d.Cleanup = opt.Cleanup
+
+	if opt.Failover && st == nil {
+		d.Failover = NewFailover(dds)
+	}
 
 	return d, nil
 
@@ -257,9 +698,14 @@ func newHTTPDownload(opt *DLOptions) (*Download, error) {
 		resumable = false
 	}
 
-	if !resumable && (opt.PartCount > 1 || opt.PartSize > 0) {
+	if !resumable && (opt.PartCount > 1 || opt.PartSize > 0 || opt.CDC.Avg > 0) {
 		fmt.Fprintf(Stderr, "%s\n", ErrMultPart)
 		opt.PartCount = 1
+		opt.CDC = CDCParams{} //content-defined parts need ranged reads too
+	}
+
+	if cl, err = opt.applyRange(cl, resumable); err != nil {
+		return nil, err
 	}
 
 	if err := opt.AlignPartCountSize(cl); err != nil {
@@ -268,10 +714,17 @@ func newHTTPDownload(opt *DLOptions) (*Download, error) {
 
 	opt.ParseBasePath(hdr)
 
+	var dg *Digest
+	if hdr.Get("Content-Range") == "" && SharedHeader.Get("Range") == "" {
+		dg = ParseDigest(hdr)
+	}
+
 	return &Download{
 		DataSize:  cl,
 		Type:      HTTP,
 		Resumable: resumable,
+		Digest:    dg,
+		Validator: NewValidator(hdr),
 	}, nil
 
 }
@@ -282,7 +735,10 @@ func newFileDownload(opt *DLOptions) (*Download, error) {
 	if err != nil {
 		return nil, err
 	}
-	fs := info.Size()
+	fs, err := opt.applyRange(info.Size(), true)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := opt.AlignPartCountSize(fs); err != nil {
 		return nil, err
@@ -294,6 +750,7 @@ func newFileDownload(opt *DLOptions) (*Download, error) {
 		DataSize:  fs,
 		Type:      File,
 		Resumable: true,
+		Validator: Validator{Modified: info.ModTime()},
 	}, nil
 
 }
@@ -324,10 +781,15 @@ func (opt *DLOptions) AlignPartCountSize(dataSize int64) error {
 		return nil
 	}
 
+	unit := AlignUnit(opt.Align, opt.RecordSize)
+
 	switch {
 	case opt.PartSize < 1:
 		opt.PartSize = UnknownSize
 	default:
+		if opt.PartSize <= dataSize {
+			opt.PartSize = min((opt.PartSize+unit-1)/unit*unit, dataSize) //round up to the unit
+		}
 		opt.PartCount = 1 + int((dataSize-1)/opt.PartSize) //ceiling division
 	}
 
@@ -335,6 +797,10 @@ func (opt *DLOptions) AlignPartCountSize(dataSize int64) error {
 		opt.PartCount = 1
 	}
 
+	if unit > 1 && opt.PartCount > 1 && int64(opt.PartCount) > dataSize/unit {
+		return NewErr("%s: %d aligned to %d", ErrPartExceed, dataSize, unit)
+	}
+
 	if opt.PartCount > int(dataSize) || opt.PartSize > dataSize {
 		return NewErr("%s: %d", ErrPartExceed, dataSize)
 	}
@@ -361,21 +827,11 @@ func (opt *DLOptions) ParseBasePath(hdr http.Header) {
 func (d *Download) DataCasterGenerator() func() (DataCaster, error) {
 
 	var (
-		gendc   func(string) (DataCaster, error)
+		gendc   = dataCasterOf(d.Type)
 		dcs     = d.Sources
 		retries = len(dcs) + 1
 		x       = 0
 	)
-	switch d.Type {
-	case File:
-		gendc = NewFileDataCaster
-	case HTTP:
-		gendc = NewHTTPDataCaster
-	default:
-		gendc = func(string) (DataCaster, error) {
-			return nil, ErrDLType
-		}
-	}
 
 	return func() (dc DataCaster, err error) {
 		if dc, err = gendc(d.URI); err != nil {
@@ -393,26 +849,49 @@ func (d *Download) DataCasterGenerator() func() (DataCaster, error) {
 
 }
 
-func (e *endpoint) copyWithRetry(retries int) (err error) {
+func dataCasterOf(t DLType) func(string) (DataCaster, error) {
 
-	go func() {
-		<-e.c.Done()
-		e.fio.Close()
-		if e.r != nil {
-			e.r.Close()
+	switch t {
+	case File:
+		return NewFileDataCaster
+	case HTTP:
+		return NewHTTPDataCaster
+	default:
+		return func(string) (DataCaster, error) {
+			return nil, ErrDLType
 		}
-	}()
+	}
+
+}
+
+func (e *endpoint) watch() {
+
+	<-e.c.Done()
+	e.w.Close()
+	if e.r != nil {
+		e.r.Close()
+	}
+
+}
+
+func (e *endpoint) copyWithRetry(retries int) (err error) {
 
 	delay := time.Duration(0)
+	tried := make(map[string]bool)
 	t := 0
 	for {
 		select {
 		case <-time.After(delay):
 
-			if e.r, err = e.dc.DataCast(e.fio.Scope); err == nil {
-				if _, err = io.Copy(e.fio, e.r); err == nil {
+			if e.r, err = e.dc.DataCast(e.w.Lead().Scope); err == nil {
+				if _, err = io.Copy(e.w, e.r); err == nil {
 					return nil
 				}
+				e.r.Close() //the next attempt opens its own
+				if e.failover(err, tried) {
+					delay = 0
+					continue
+				}
 			}
 
 			if t++; t >= retries {
@@ -422,7 +901,7 @@ func (e *endpoint) copyWithRetry(retries int) (err error) {
 				return err
 			}
 
-			if err = e.fio.SetOffset(); err != nil {
+			if err = e.w.SetOffset(); err != nil {
 				return err
 			}
 
@@ -435,3 +914,12 @@ func (e *endpoint) copyWithRetry(retries int) (err error) {
 	}
 
 }
+
+func (e *endpoint) failover(err error, tried map[string]bool) bool {
+
+	if e.fo == nil || len(e.w.fios) != 1 || !IsDiskErr(err) {
+		return false
+	}
+	return e.fo.Move(e.w.Lead(), tried) == nil
+
+}
@@ -103,3 +103,37 @@ func TestNewDownload(t *testing.T) {
 	}
 
 }
+
+func TestStream(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := make([]byte, 100000)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	os.WriteFile("test/src.bin", data, 0644)
+
+	newOpt := opt
+	newOpt.URI = "test/src.bin"
+	newOpt.BasePath = ""
+	newOpt.DstDirs = nil
+	newOpt.PartCount = 7
+	newOpt.Output = "test/out.bin"
+	newOpt.Window = 2
+
+	d, err := NewDownload(&newOpt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if err = d.Start(); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	out, _ := os.ReadFile("test/out.bin")
+	if string(out) != string(data) {
+		t.Errorf("streamed output does not match the source")
+	}
+
+}
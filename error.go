@@ -30,18 +30,43 @@ var (
 
 	Stderr = os.Stderr
 
-	ErrCancel     = NewErr("canceled")
-	ErrAbort      = NewErr("aborted")
-	ErrPartExceed = NewErr("part total count or size exceeds the source file size")
-	ErrFileURL    = NewErr("inaccessible file or invalid URI")
-	ErrDLType     = NewErr("unknown download type")
-	ErrExhaust    = NewErr("resource exhausted")
-	ErrArgs       = NewErr("invalid argument")
-	ErrParse      = NewErr("parse error")
-	ErrPartLimit  = NewErr("exceeds output file count limit")
-	ErrMultPart   = NewErr("server does not support multipart or segmented downloads")
-	ErrRedir      = NewErr("redirected")
-	ErrVer        = NewErr("version requested")
+	ErrCancel      = NewErr("canceled")
+	ErrAbort       = NewErr("aborted")
+	ErrPartExceed  = NewErr("part total count or size exceeds the source file size")
+	ErrFileURL     = NewErr("inaccessible file or invalid URI")
+	ErrDLType      = NewErr("unknown download type")
+	ErrExhaust     = NewErr("resource exhausted")
+	ErrArgs        = NewErr("invalid argument")
+	ErrParse       = NewErr("parse error")
+	ErrPartLimit   = NewErr("exceeds output file count limit")
+	ErrMultPart    = NewErr("server does not support multipart or segmented downloads")
+	ErrRedir       = NewErr("redirected")
+	ErrVer         = NewErr("version requested")
+	ErrBroken      = NewErr("broken file part")
+	ErrNoSpace     = NewErr("insufficient free space")
+	ErrPrealloc    = NewErr("preallocation is not supported on this platform")
+	ErrManifest    = NewErr("invalid manifest")
+	ErrFreeSpace   = NewErr("unable to determine free space")
+	ErrNoCapacity  = NewErr("destination directories cannot hold all parts")
+	ErrWrite       = NewErr("write error")
+	ErrNoFailover  = NewErr("no other destination directory can hold the file part")
+	ErrReplica     = NewErr("invalid replica layout")
+	ErrMap         = NewErr("invalid part map")
+	ErrTemplate    = NewErr("invalid name template")
+	ErrRange       = NewErr("invalid byte range")
+	ErrSelect      = NewErr("invalid part selection")
+	ErrNoClaim     = NewErr("no part left to claim")
+	ErrLease       = NewErr("lease taken over by another shard")
+	ErrRebalance   = NewErr("unable to move part to its new directory")
+	ErrSiblings    = NewErr("unable to reuse part files from an earlier run")
+	ErrRepair      = NewErr("unable to repair broken file part")
+	ErrVerify      = NewErr("unable to verify file part against the source")
+	ErrPieces      = NewErr("piece hash mismatch or invalid piece list")
+	ErrDigest      = NewErr("content digest mismatch")
+	ErrSig         = NewErr("signature verification failed")
+	ErrNotModified = NewErr("not modified since the last download")
+	ErrModified    = NewErr("remote file changed since the last download, starting over")
+	ErrXattr       = NewErr("extended attributes are not supported here")
 )
 
 func catchErr(errCh chan error, maxErrCount int) (err error) {
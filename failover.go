@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+type (
+	Failover struct {
+		Dirs []string
+		mtx  sync.Mutex
+	}
+)
+
+func NewFailover(dds []DstDir) *Failover {
+
+	fo := &Failover{}
+	for _, dd := range dds {
+		fo.Dirs = append(fo.Dirs, dd.Path)
+	}
+	return fo
+
+}
+
+func IsDiskErr(err error) bool {
+
+	return IsErr(err, ErrWrite) &&
+		(IsErr(err, syscall.ENOSPC) || IsErr(err, syscall.EIO))
+
+}
+
+func (fo *Failover) Move(fio *FileIO, tried map[string]bool) error {
+
+	fo.mtx.Lock()
+	defer fo.mtx.Unlock()
+
+	cur := filepath.Dir(fio.Path.Relative)
+	tried[cur] = true
+
+	for _, dir := range fo.Dirs {
+
+		relv := fio.Path.In(dir)
+		if tried[filepath.Dir(relv)] {
+			continue
+		}
+		tried[filepath.Dir(relv)] = true
+
+		free, err := freeSpace(dirOrDot(dir))
+		if err != nil || (free >= 0 && free < fio.ScopeSize()) {
+			continue
+		}
+
+		if err := fio.moveTo(dir, relv); err != nil {
+			continue
+		}
+		return nil
+
+	}
+
+	return NewErr("%w: %s", ErrNoFailover, fio.Path.Relative)
+
+}
+
+func (fio *FileIO) moveTo(dir, relv string) error {
+
+	fio.Close()
+	old := fio.Path.Relative
+
+	if err := os.Rename(old, relv); err != nil {
+		if err := copyFilePrefix(old, relv, fio.headSize()+fio.PullOffset()); err != nil {
+			os.Remove(relv)
+			fio.Open()
+			return err
+		}
+		os.Remove(old)
+	}
+
+	fp := fio.PullPath()
+	fp.DstDir, fp.Relative = dir, relv
+	fio.PushPath(fp)
+
+	if err := fio.Open(); err != nil {
+		return err
+	}
+	return fio.SeekOffset()
+
+}
+
+func copyFilePrefix(src, dst string, n int64) error {
+
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(w, r, n); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+
+}
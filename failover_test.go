@@ -0,0 +1,75 @@
+package partdec
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFailoverMove(t *testing.T) {
+
+	os.MkdirAll("test/a/", 0750)
+	os.MkdirAll("test/b/", 0750)
+	defer os.RemoveAll("test/")
+
+	fio, err := newPartFileIO("part", "test/a", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer fio.Close()
+	fio.Scope = ByteRange{Start: 0, End: 9}
+
+	if _, err := fio.Write([]byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fo := NewFailover([]DstDir{{Path: "test/a"}, {Path: "test/b"}})
+	tried := make(map[string]bool)
+	if err := fo.Move(fio, tried); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if fio.Path.Relative != "test/b/part" {
+		t.Errorf("got path %s, want test/b/part", fio.Path.Relative)
+	}
+	if fio.PullOffset() != 5 {
+		t.Errorf("got offset %d, want 5", fio.PullOffset())
+	}
+	if IsFile("test/a/part") {
+		t.Errorf("test/a/part is left behind")
+	}
+
+	if _, err := fio.Write([]byte("world")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	fio.Close()
+
+	if b, _ := os.ReadFile("test/b/part"); string(b) != "helloworld" {
+		t.Errorf("got %q, want %q", b, "helloworld")
+	}
+
+	if err := fo.Move(fio, tried); !IsErr(err, ErrNoFailover) {
+		t.Errorf("got %v, want %s: both directories were tried", err, ErrNoFailover)
+	}
+
+}
+
+func TestIsDiskErr(t *testing.T) {
+
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{NewErr("%w: %w", ErrWrite, syscall.ENOSPC), true},
+		{NewErr("%w: %w", ErrWrite, syscall.EIO), true},
+		{NewErr("%w: %w", ErrWrite, syscall.EACCES), false},
+		{syscall.ENOSPC, false}, //a read error is not the disk's
+	}
+
+	for _, c := range cases {
+		if got := IsDiskErr(c.err); got != c.want {
+			t.Errorf("IsDiskErr(%v) = %t, want %t", c.err, got, c.want)
+		}
+	}
+
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package partdec
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -30,6 +29,7 @@ type (
 
 	FilePath struct {
 		Base, DstDir, Relative string
+		Temp                   bool
 	}
 
 	ByteRange struct {
@@ -39,15 +39,22 @@ type (
 
 	FileIO struct {
 		*os.File
-		Scope  ByteRange
-		State  FileState
-		Path   FilePath
-		Oflag  int
-		Perm   os.FileMode
-		isOpen bool
+		Scope    ByteRange
+		State    FileState
+		Path     FilePath
+		Oflag    int
+		Perm     os.FileMode
+		Replicas FileIOs
+		Header   []byte
+		Synced   int64
+		isOpen   bool
 	}
 
 	FileIOs []*FileIO
+
+	writerOnly struct {
+		io.Writer
+	}
 )
 
 const (
@@ -63,36 +70,17 @@ const (
 	PathSeparator = string(os.PathSeparator)
 )
 
-func BuildFileIOs(partCount int, base string, dirs []string) (FileIOs, error) {
-
-	if dirs == nil {
-		dirs = []string{""}
-	}
-
-	fios := make(FileIOs, partCount)
-	dirCount := len(dirs)
-	perDir := partCount / dirCount
-	remainer := partCount % dirCount
-	addIndex := FileNameIndexer(partCount)
-
-	var i, e int
-	for _, d := range dirs {
-
-		if e = 0; remainer > 0 {
-			e = 1
-			remainer--
-		}
+func BuildFileIOs(dp DirPlan, name FileNamer, atomic bool) (FileIOs, error) {
 
-		for range perDir + e {
+	fios := make(FileIOs, len(dp))
 
-			fio, err := NewFileIO(addIndex(base), d, os.O_CREATE|os.O_WRONLY)
-			if err != nil {
-				return nil, err
-			}
-			fios[i] = fio
-			i++
+	for i, d := range dp {
 
+		fio, err := newPartFileIO(name(i, d), d, atomic)
+		if err != nil {
+			return nil, err
 		}
+		fios[i] = fio
 
 	}
 
@@ -102,14 +90,7 @@ func BuildFileIOs(partCount int, base string, dirs []string) (FileIOs, error) {
 
 func NewFileIO(base, dir string, oflag int) (*FileIO, error) {
 
-	var relv string
-
-	switch {
-	case dir == "":
-		relv = filepath.Clean(base)
-	default:
-		relv = filepath.Clean(dir + PathSeparator + base)
-	}
+	relv := newRelativePath(base, dir)
 
 	f, err := os.OpenFile(relv, oflag, FilePerm)
 
@@ -131,6 +112,35 @@ func NewFileIO(base, dir string, oflag int) (*FileIO, error) {
 
 }
 
+func newPartFileIO(base, dir string, atomic bool) (*FileIO, error) {
+
+	oflag := os.O_CREATE | os.O_WRONLY
+	relv := newRelativePath(base, dir)
+
+	if IsFile(relv) || !(atomic || IsFile(relv+TmpExt)) {
+		return NewFileIO(base, dir, oflag)
+	}
+
+	fio, err := NewFileIO(base+TmpExt, dir, oflag)
+	if err != nil {
+		return nil, err
+	}
+	fio.Path.Base = base
+	fio.Path.Temp = true
+
+	return fio, nil
+
+}
+
+func newRelativePath(base, dir string) string {
+
+	if dir == "" {
+		return filepath.Clean(base)
+	}
+	return filepath.Clean(dir + PathSeparator + base)
+
+}
+
 func (fio *FileIO) DataCast(br ByteRange) (io.ReadCloser, error) {
 
 	rangeStart := br.Start + br.Offset
@@ -167,9 +177,20 @@ func NewFileDataCaster(path string) (DataCaster, error) {
 
 }
 
+func (fr FileResets) isSet() bool {
+
+	for _, reset := range fr {
+		if reset {
+			return true
+		}
+	}
+	return false
+
+}
+
 func (fios FileIOs) RenewByState(fr FileResets) error {
 
-	for _, fio := range fios {
+	for _, fio := range fios.All() {
 
 		if fio.State == Unknown || (fr != nil && fr[fio.State]) {
 
@@ -191,16 +212,77 @@ func (fios FileIOs) RenewByState(fr FileResets) error {
 
 }
 
+func (fios FileIOs) CheckFreeSpace() error {
+
+	var dirs []string
+	need := make(map[string]int64)
+
+	for _, fio := range fios.All() {
+
+		if fio.State != New && fio.State != Resume {
+			continue
+		}
+
+		size, _ := fio.DataSize()
+		n := fio.ScopeSize() - max(size, 0)
+		if n < 1 {
+			continue
+		}
+
+		dir := filepath.Dir(fio.Path.Relative)
+		if _, ok := need[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		need[dir] += n
+
+	}
+
+	for _, dir := range dirs {
+		free, err := freeSpace(dir)
+		if err != nil || free < 0 {
+			continue //free space is unknown
+		}
+		if free < need[dir] {
+			return NewErr("%s: %s needs %s, %s available",
+				ErrNoSpace, dir, toEIC(need[dir]), toEIC(free))
+		}
+	}
+
+	return nil
+
+}
+
+func (fios FileIOs) Preallocate() error {
+
+	for _, fio := range fios.All() {
+
+		if fio.State != New && fio.State != Resume {
+			continue
+		}
+
+		if err := fio.Open(); err != nil {
+			return err
+		}
+		if err := preallocate(fio.File, fio.headSize()+fio.ScopeSize()); err != nil {
+			return err
+		}
+
+	}
+	return nil
+
+}
+
 func (fios FileIOs) SetInitialState() error {
 
-	for _, fio := range fios {
-		size, err := fio.Size()
+	for _, fio := range fios.All() {
+		size, err := fio.DataSize()
 		if err != nil {
 			return err
 		}
 
 		rs := fio.Scope.Start
 		re := fio.Scope.End
+		offset := fio.Scope.Offset
 
 		switch {
 		case rs == UnknownSize || re == UnknownSize:
@@ -209,9 +291,9 @@ func (fios FileIOs) SetInitialState() error {
 			fio.State = Broken
 		case size > re-rs+1:
 			fio.State = Broken
-		case size == re-rs+1:
+		case offset == re-rs+1:
 			fio.State = Completed
-		case size > 0:
+		case offset > 0:
 			fio.State = Resume
 		default:
 			fio.State = New
@@ -221,35 +303,54 @@ func (fios FileIOs) SetInitialState() error {
 
 }
 
-func (fios FileIOs) SetByteRange(dataSize int64, partSize int64) error {
+func (fios FileIOs) SetByteRange(brs []ByteRange) error {
+
+	for i, fio := range fios {
+		for _, m := range fio.Members() {
+
+			m.Scope.Start = brs[i].Start
+			m.Scope.End = brs[i].End
+
+			if brs[i].End == UnknownSize {
+				continue
+			}
+
+			if size, err := m.DataSize(); err != nil {
+				return err
+			} else {
+				m.Scope.Offset = size
+			}
 
-	if dataSize < 0 {
-		for _, fio := range fios {
-			fio.Scope.Start = 1 // end - start + 1 = -1
-			fio.Scope.End = UnknownSize
 		}
-		return nil
 	}
 
-	if partSize > 0 {
-		if err := fios.setByteRangeByPartSize(dataSize, partSize); err != nil {
-			return err
-		}
-	} else {
-		if err := fios.setByteRangeByPartCount(dataSize); err != nil {
-			return err
+	return nil
+
+}
+
+func SplitByteRange(dataSize int64, partCount int, partSize int64) []ByteRange {
+
+	if dataSize < 0 {
+		brs := make([]ByteRange, partCount)
+		for i := range brs {
+			brs[i].Start = 1 // end - start + 1 = -1
+			brs[i].End = UnknownSize
 		}
+		return brs
 	}
 
-	return nil
+	if partSize > 0 {
+		return byteRangeByPartSize(dataSize, partSize)
+	}
+	return byteRangeByPartCount(dataSize, partCount)
 
 }
 
-func (fios FileIOs) setByteRangeByPartCount(dataSize int64) error {
+func byteRangeByPartCount(dataSize int64, partCount int) []ByteRange {
 
 	var rangeStart, rangeEnd, offset, e int64
 
-	partCount := len(fios)
+	brs := make([]ByteRange, partCount)
 	basePartSize := dataSize / int64(partCount)
 	remainder := dataSize % int64(partCount)
 
@@ -265,28 +366,77 @@ func (fios FileIOs) setByteRangeByPartCount(dataSize int64) error {
 		rangeEnd = (rangeStart - 1) + basePartSize + e
 		offset = offset + e
 
-		fio := fios[i]
+		brs[i].Start = rangeStart
+		brs[i].End = rangeEnd
 
-		fio.Scope.Start = rangeStart
-		fio.Scope.End = rangeEnd
+	}
 
-		if size, err := fio.Size(); err != nil {
-			return err
-		} else {
-			fio.Scope.Offset = size
-		}
+	return brs
+
+}
+
+func SplitAlignedByteRange(dataSize int64, partCount int, partSize, unit int64) []ByteRange {
 
+	if unit <= 1 || dataSize < 0 || partSize > 0 {
+		return SplitByteRange(dataSize, partCount, partSize)
 	}
 
-	return nil
+	brs := make([]ByteRange, partCount)
+	basePartSize := (dataSize / int64(partCount)) / unit * unit
+
+	for i := range brs {
+		brs[i].Start = int64(i) * basePartSize
+		brs[i].End = brs[i].Start + basePartSize - 1
+	}
+	brs[partCount-1].End = dataSize - 1 //the remainder goes to the last part
+
+	return brs
+
+}
+
+func AlignByteRange(brs []ByteRange, origin, unit int64) []ByteRange {
+
+	if unit <= 1 || len(brs) == 0 || origin%unit == 0 {
+		return brs
+	}
+
+	end := brs[len(brs)-1].End
+	start := brs[0].Start
+
+	var aligned []ByteRange
+	for _, br := range brs[1:] {
+		next := (origin+br.Start+unit-1)/unit*unit - origin //the next multiple of unit in the source
+		if next > end {
+			break
+		}
+		if next <= start {
+			continue
+		}
+		aligned = append(aligned, ByteRange{Start: start, End: next - 1})
+		start = next
+	}
+	return append(aligned, ByteRange{Start: start, End: end})
+
+}
+
+func AlignUnit(align, recordSize int64) int64 {
+
+	a, b := max(align, 1), max(recordSize, 1)
+	for x, y := a, b; ; {
+		if y == 0 {
+			return a / x * b //least common multiple
+		}
+		x, y = y, x%y
+	}
 
 }
 
-func (fios FileIOs) setByteRangeByPartSize(dataSize, partSize int64) error {
+func byteRangeByPartSize(dataSize, partSize int64) []ByteRange {
 
 	var rangeStart, rangeEnd, offset int64
 
 	partCount := 1 + int((dataSize-1)/partSize) //ceiling division
+	brs := make([]ByteRange, partCount)
 
 	var i int
 	for i, offset = 0, 0; i < partCount; i, offset = i+1, offset+partSize {
@@ -299,20 +449,12 @@ func (fios FileIOs) setByteRangeByPartSize(dataSize, partSize int64) error {
 			rangeEnd = (rangeStart - 1) + partSize
 		}
 
-		fio := fios[i]
-
-		fio.Scope.Start = rangeStart
-		fio.Scope.End = rangeEnd
-
-		if size, err := fio.Size(); err != nil {
-			return err
-		} else {
-			fio.Scope.Offset = size
-		}
+		brs[i].Start = rangeStart
+		brs[i].End = rangeEnd
 
 	}
 
-	return nil
+	return brs
 
 }
 
@@ -322,41 +464,6 @@ func newFileNameFromPath(path string) string {
 
 }
 
-func FileNameIndexer(maxIndex int) func(string) string {
-
-	if maxIndex <= 1 {
-		return func(name string) string {
-			return name
-		}
-	}
-	countDigits := func(n int) int {
-		count := 0
-		switch {
-		case n == 0:
-			return 1
-		case n < 0:
-			n = -n
-		}
-
-		for n > 0 {
-			n /= 10
-			count++
-		}
-		return count
-	}
-	pad := countDigits(maxIndex)
-	currentIndex := 0
-
-	return func(name string) string {
-		if currentIndex < maxIndex {
-			currentIndex++
-			return fmt.Sprintf("%s_%0*d", name, pad, currentIndex)
-		}
-		return name
-	}
-
-}
-
 func (s FileState) String() string {
 
 	switch s {
@@ -384,13 +491,51 @@ func (fio *FileIO) Size() (int64, error) {
 
 }
 
+func (fio *FileIO) DataSize() (int64, error) {
+
+	size, err := fio.Size()
+	if err != nil || size == UnknownSize {
+		return size, err
+	}
+	return max(size-fio.headSize(), 0), nil
+
+}
+
+func (fio *FileIO) headSize() int64 {
+
+	return int64(len(fio.Header))
+
+}
+
+func (fio *FileIO) SeekOffset() error {
+
+	if fio.headSize() > 0 && fio.Scope.Offset == 0 {
+		if _, err := fio.File.WriteAt(fio.Header, 0); err != nil {
+			return NewErr("%w: %w", ErrWrite, err)
+		}
+	}
+
+	_, err := fio.Seek(fio.headSize()+fio.Scope.Offset, io.SeekStart)
+	return err
+
+}
+
+func (fios FileIOs) SetHeader(hdr []byte) {
+
+	for _, fio := range fios {
+		for _, m := range fio.Members() {
+			m.Header = hdr
+		}
+	}
+
+}
+
 func (fios FileIOs) TotalSize() int64 {
 
-	var totalSize, size int64
+	var totalSize int64
 
 	for _, fio := range fios {
-		size, _ = fio.Size()
-		totalSize += size
+		totalSize += fio.Lead().PullOffset()
 	}
 	return totalSize
 
@@ -403,18 +548,11 @@ func (fio *FileIO) SetOffset() error {
 		if err := fio.Truncate(0); err != nil {
 			return err
 		}
-		fio.Seek(0, io.SeekEnd)
-		fio.Scope.Offset = 0
-		return nil
+		fio.PushOffset(0)
 
 	}
 
-	if size, err := fio.Size(); err != nil {
-		return err
-	} else {
-		fio.Scope.Offset = size
-	}
-	return nil
+	return fio.SeekOffset()
 
 }
 
@@ -437,6 +575,10 @@ func (fio *FileIO) Open() (err error) {
 		return err
 	}
 
+	mtx.Lock()
+	defer mtx.Unlock()
+	fio.isOpen = true
+
 	return nil
 
 }
@@ -492,3 +634,62 @@ func (fio *FileIO) PushState(fs FileState) {
 	fio.State = fs
 
 }
+
+func (fio *FileIO) Write(b []byte) (int, error) {
+
+	n, err := fio.File.Write(b)
+	if err != nil {
+		err = NewErr("%w: %w", ErrWrite, err)
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	fio.Scope.Offset += int64(n)
+
+	return n, err
+
+}
+
+func (fio *FileIO) ReadFrom(r io.Reader) (int64, error) {
+
+	return io.Copy(writerOnly{fio}, r) //keeps every write tracked
+
+}
+
+func (fio *FileIO) PullOffset() int64 {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	return fio.Scope.Offset
+
+}
+
+func (fio *FileIO) PushOffset(offset int64) {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	fio.Scope.Offset = offset
+
+}
+
+func (fio *FileIO) PullPath() FilePath {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	return fio.Path
+
+}
+
+func (fio *FileIO) PushPath(fp FilePath) {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	fio.Path = fp
+
+}
+
+func (fio *FileIO) ScopeSize() int64 {
+
+	return (fio.Scope.End - fio.Scope.Start) + 1
+
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package partdec
 
 import (
+	"context"
 	"sync"
 )
 
@@ -43,3 +44,14 @@ func (fc *FlowControl) Acquire() {
 func (fc *FlowControl) Release() {
 	<-fc.Limiter
 }
+
+func (fc *FlowControl) AcquireCtx(c context.Context) error {
+
+	select {
+	case fc.Limiter <- struct{}{}:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	FsyncMode uint8
+
+	FsyncPolicy struct {
+		Mode     FsyncMode
+		Interval time.Duration
+	}
+)
+
+const (
+	FsyncNever FsyncMode = iota
+	FsyncPart
+	FsyncInterval
+)
+
+func (fio *FileIO) SyncData() error {
+
+	var err error
+	for _, m := range fio.Members() {
+		if m.PullState() == Broken {
+			continue
+		}
+		err = JoinErr(err, m.syncData())
+	}
+	return err
+
+}
+
+func (fio *FileIO) syncData() error {
+
+	offset := fio.PullOffset() //taken before the sync as a lower bound
+	path := fio.PullPath().Relative
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	fio.PushSynced(offset)
+	return nil
+
+}
+
+func (fio *FileIO) SyncDirs() error {
+
+	var err error
+	for _, m := range fio.Members() {
+		err = JoinErr(err, syncDir(filepath.Dir(m.PullPath().Relative)))
+	}
+	return err
+
+}
+
+func (fio *FileIO) PullSynced() int64 {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	return fio.Synced
+
+}
+
+func (fio *FileIO) PushSynced(offset int64) {
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	fio.Synced = offset
+
+}
+
+func (d *Download) syncLoop() {
+
+	defer d.Flow.WG.Done()
+
+	tick := time.NewTicker(d.Fsync.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			for _, fio := range d.Files {
+				if s := fio.Lead().PullState(); s == New || s == Resume {
+					fio.SyncData()
+				}
+			}
+		case <-d.Ctx.Done():
+			return
+		}
+	}
+
+}
+
+func (d *Download) settle(fio *FileIO) error {
+
+	err := fio.RepairReplicas()
+
+	if d.Fsync.Mode == FsyncNever {
+		return JoinErr(err, fio.Commit())
+	}
+
+	err = JoinErr(err, fio.SyncData())
+	if cerr := fio.Commit(); cerr != nil {
+		return JoinErr(err, cerr)
+	}
+	return JoinErr(err, fio.SyncDirs()) //makes the rename durable
+
+}
+
+func (fp *FsyncPolicy) String() string {
+
+	switch fp.Mode {
+	case FsyncPart:
+		return "part"
+	case FsyncInterval:
+		return "interval:" + fp.Interval.String()
+	default:
+		return "never"
+	}
+
+}
+
+func (fp *FsyncPolicy) Type() string {
+	return "FsyncPolicy"
+}
+
+func (fp *FsyncPolicy) Set(value string) error {
+
+	mode, interval, _ := strings.Cut(strings.TrimSpace(value), ":")
+
+	switch mode {
+	case "never":
+		*fp = FsyncPolicy{Mode: FsyncNever}
+	case "part":
+		*fp = FsyncPolicy{Mode: FsyncPart}
+	case "interval":
+		d, err := time.ParseDuration(interval)
+		if err != nil || d <= 0 {
+			return ErrParse
+		}
+		*fp = FsyncPolicy{Mode: FsyncInterval, Interval: d}
+	default:
+		return ErrParse
+	}
+
+	return nil
+
+}
@@ -0,0 +1,78 @@
+package partdec
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func syncRun(t *testing.T, mode FsyncMode) {
+
+	newOpt := DLOptions{
+		URI:       "test/src",
+		BasePath:  "out",
+		DstDirs:   []string{"test/sync"},
+		PartCount: 2,
+		Fsync:     FsyncPolicy{Mode: mode},
+		NoVerify:  true, //keeps the tail check from covering for the manifest
+		Mod:       &IOMod{},
+	}
+
+	d, err := NewDownload(&newOpt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = d.Start(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+}
+
+func TestResumeFromSynced(t *testing.T) {
+
+	src := bytes.Repeat([]byte("0123456789"), 100)
+
+	cases := []struct {
+		mode    FsyncMode
+		durable bool
+	}{
+		{FsyncPart, true},
+		{FsyncNever, false},
+	}
+
+	for _, c := range cases {
+
+		os.MkdirAll("test/sync", 0750)
+		os.WriteFile("test/src", src, 0644)
+
+		syncRun(t, c.mode)
+
+		m, err := LoadManifest(ManifestPath("out", []string{"test/sync"}))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		for i, mp := range m.Parts {
+			if mp.Path == "test/sync/out_1" {
+				m.Parts[i].Synced = 200 //the rest was written but lost in a crash
+			}
+		}
+		if err := m.Save(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		part, _ := os.ReadFile("test/sync/out_1")
+		copy(part[200:], bytes.Repeat([]byte("x"), 300))
+		os.WriteFile("test/sync/out_1", part, 0644)
+
+		syncRun(t, c.mode)
+
+		got, _ := os.ReadFile("test/sync/out_1")
+		if intact := bytes.Equal(got, src[:500]); intact != c.durable {
+			t.Errorf("%d: got the unsynced bytes fetched again %t, want %t", c.mode, intact, c.durable)
+		}
+
+		os.RemoveAll("test/")
+
+	}
+
+}
@@ -7,4 +7,10 @@ require (
 	golang.org/x/term v0.25.0
 )
 
-require github.com/spf13/pflag v1.0.5
+require (
+	github.com/ProtonMail/go-crypto v1.1.6
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/crypto v0.28.0
+)
+
+require github.com/cloudflare/circl v1.3.7 // indirect
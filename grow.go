@@ -0,0 +1,228 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+func (opt *DLOptions) grow(d *Download, p *prober) (brs []ByteRange, grew bool, err error) {
+
+	m := d.Manifest
+	if m == nil || opt.Shard != nil || opt.Only != nil || opt.CSV || opt.origin != 0 ||
+		opt.parts != nil || opt.ReDL.isSet() {
+		return nil, false, nil
+	}
+
+	wasGrown := m.Grown
+	m.Grown = false
+
+	switch {
+	case m.DataSize < 1 || d.DataSize < m.DataSize:
+		return nil, false, nil
+	case d.DataSize == m.DataSize:
+		if brs = m.ranges(false); wasGrown && brs != nil {
+			m.Grown = true //keeps the grown layout until it is reset
+			return brs, false, nil
+		}
+		return nil, false, nil
+	}
+
+	olds := m.ranges(true)
+	if olds == nil {
+		return nil, false, nil //an unfinished download resumes as usual
+	}
+
+	same, checked, err := m.overlaps(p)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case !checked:
+		return nil, false, nil
+	case !same:
+		fmt.Fprintf(Stderr, "%s: %s\n", ErrModified, filepath.Base(opt.BasePath))
+		opt.ReDL = FileResets{Resume: true, Completed: true, Broken: true}
+		m.discard()
+		return nil, false, nil
+	}
+
+	brs = GrowByteRange(olds, d.DataSize, growUnit(olds, opt.PartSize))
+
+	last := olds[len(olds)-1]
+	for i := range m.Parts {
+		if m.Parts[i].Start == last.Start && m.Parts[i].End == last.End {
+			m.Parts[i].End = brs[len(olds)-1].End //the last part resumes
+		}
+	}
+	m.Pieces = nil //hashes of the old size no longer fit
+	m.Grown = true
+
+	fmt.Fprintf(Stderr, "grown: %s to %s, fetching the last %s\n",
+		toEIC(m.DataSize), toEIC(d.DataSize), toEIC(d.DataSize-m.DataSize))
+
+	return brs, true, nil
+
+}
+
+func (m *Manifest) ranges(complete bool) []ByteRange {
+
+	var brs []ByteRange
+	for _, mp := range m.Parts {
+		br := ByteRange{Start: mp.Start, End: mp.End}
+		if complete && mp.Offset < mp.End-mp.Start+1 || slices.Contains(brs, br) { //replicas share a range
+			continue
+		}
+		if _, err := os.Stat(mp.Path); complete && err != nil {
+			continue
+		}
+		brs = append(brs, br)
+	}
+	slices.SortFunc(brs, func(a, b ByteRange) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+
+	var next int64
+	for _, br := range brs {
+		if br.Start != next {
+			return nil
+		}
+		next = br.End + 1
+	}
+	if next != m.DataSize {
+		return nil
+	}
+	return brs
+
+}
+
+func (m *Manifest) overlaps(p *prober) (same, checked bool, err error) {
+
+	for _, mp := range m.Parts {
+		if mp.End != m.DataSize-1 || mp.Offset < mp.End-mp.Start+1 {
+			continue
+		}
+		if same, checked, err := p.sameTail(mp); err != nil || checked {
+			return same, checked, err
+		}
+	}
+
+	return false, false, nil
+
+}
+
+func (p *prober) sameTail(mp ManifestPart) (same, checked bool, err error) {
+
+	n := min(verifySize, mp.Offset)
+	if n < 1 {
+		return false, false, nil
+	}
+	local := make([]byte, n)
+
+	f, err := os.Open(mp.Path)
+	if err != nil {
+		return false, false, nil
+	}
+	_, err = f.ReadAt(local, mp.Head+mp.Offset-n)
+	f.Close()
+	if err != nil {
+		return false, false, nil
+	}
+
+	remote, err := p.readAt(mp.Start+mp.Offset-n, n)
+	if err != nil {
+		return false, false, err
+	}
+	return bytes.Equal(local, remote), true, nil
+
+}
+
+func (m *Manifest) rename(paths []string, brs []ByteRange) error {
+
+	renamed := 0
+	for i, br := range brs {
+
+		for j := range m.Parts {
+
+			mp := &m.Parts[j]
+			if mp.Start != br.Start || mp.End != br.End {
+				continue
+			}
+
+			dst := newRelativePath(filepath.Base(paths[i]), filepath.Dir(mp.Path)) //replicas keep their directory
+			if filepath.Ext(mp.Path) == TmpExt {
+				dst += TmpExt
+			}
+			if dst == mp.Path || !IsFile(mp.Path) {
+				continue
+			}
+			if IsFile(dst) {
+				return NewErr("%s: %s: %s", ErrRebalance, mp.Path, os.ErrExist)
+			}
+
+			if err := os.Rename(mp.Path, dst); err != nil {
+				return NewErr("%s: %s: %w", ErrRebalance, mp.Path, err)
+			}
+			mp.Path = dst
+			renamed++
+
+		}
+
+	}
+
+	if renamed == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(Stderr, "renamed: %d parts to fit the grown layout\n", renamed)
+	return m.Save()
+
+}
+
+func growUnit(olds []ByteRange, partSize int64) int64 {
+
+	switch {
+	case partSize > 0:
+		return partSize
+	case len(olds) > 1:
+		return olds[0].End - olds[0].Start + 1
+	}
+	return 0 //a single part just gets longer
+
+}
+
+func GrowByteRange(brs []ByteRange, dataSize, unit int64) []ByteRange {
+
+	grown := slices.Clone(brs)
+	last := &grown[len(grown)-1]
+
+	if unit < 1 {
+		last.End = dataSize - 1
+		return grown
+	}
+
+	last.End = max(last.End, min(last.Start+unit, dataSize)-1)
+	for start := last.End + 1; start < dataSize; start += unit {
+		grown = append(grown, ByteRange{Start: start, End: min(start+unit, dataSize) - 1})
+	}
+	return grown
+
+}
@@ -0,0 +1,142 @@
+package partdec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestGrowByteRange(t *testing.T) {
+
+	olds := []ByteRange{{Start: 0, End: 9}, {Start: 10, End: 19}, {Start: 20, End: 27}}
+
+	tests := []struct {
+		size, unit int64
+		want       []ByteRange
+	}{
+		{45, 10, []ByteRange{{Start: 0, End: 9}, {Start: 10, End: 19}, {Start: 20, End: 29}, {Start: 30, End: 39}, {Start: 40, End: 44}}},
+		{29, 10, []ByteRange{{Start: 0, End: 9}, {Start: 10, End: 19}, {Start: 20, End: 28}}},
+		{45, 0, []ByteRange{{Start: 0, End: 9}, {Start: 10, End: 19}, {Start: 20, End: 44}}},
+	}
+
+	for i, tt := range tests {
+		if got := GrowByteRange(olds, tt.size, tt.unit); !slices.Equal(got, tt.want) {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+
+	if olds[2].End != 27 {
+		t.Errorf("the old ranges were changed")
+	}
+
+}
+
+func TestManifestRanges(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	for _, p := range []string{"test/a_1", "test/a_2"} {
+		os.WriteFile(p, []byte("x"), 0644)
+	}
+
+	m := &Manifest{DataSize: 20, Parts: []ManifestPart{
+		{Path: "test/a_2", Start: 10, End: 19, Offset: 10},
+		{Path: "test/a_1", Start: 0, End: 9, Offset: 10},
+	}}
+
+	want := []ByteRange{{Start: 0, End: 9}, {Start: 10, End: 19}}
+	if got := m.ranges(true); !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	m.Parts[0].Offset = 5
+	if got := m.ranges(true); got != nil {
+		t.Errorf("unfinished: got %v, want nil", got)
+	}
+	if got := m.ranges(false); !slices.Equal(got, want) {
+		t.Errorf("any: got %v, want %v", got, want)
+	}
+
+}
+
+func growRun(t *testing.T, src, base string) {
+
+	newOpt := DLOptions{
+		URI:       src,
+		BasePath:  base,
+		DstDirs:   []string{"test/grow"},
+		PartCount: 9,
+		Mod:       &IOMod{},
+	}
+
+	d, err := NewDownload(&newOpt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err = d.Start(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+}
+
+func TestGrowParts(t *testing.T) {
+
+	os.MkdirAll("test/grow", 0750)
+	defer os.RemoveAll("test/")
+
+	src := "test/src.bin"
+	data := make([]byte, 900)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	os.WriteFile(src, data, 0640)
+
+	growRun(t, src, "out.bin")
+	first, err := os.Stat("test/grow/out.bin_1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data = append(data, bytes.Repeat([]byte{7}, 150)...) //9 parts of 100 become 11
+	os.WriteFile(src, data, 0640)
+	growRun(t, src, "out.bin")
+
+	var got []byte
+	for i := range 11 {
+		b, err := os.ReadFile(fmt.Sprintf("test/grow/out.bin_%02d", i+1))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		got = append(got, b...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("the grown parts do not join to the source")
+	}
+	if IsFile("test/grow/out.bin_1") {
+		t.Errorf("an old name was left behind")
+	}
+	if again, err := os.Stat("test/grow/out.bin_01"); err != nil || !os.SameFile(first, again) {
+		t.Errorf("the first part was downloaded again")
+	}
+
+	data[1040] ^= 0xff //the old tail no longer matches, so it starts over
+	data = append(data, 1)
+	os.WriteFile(src, data, 0640)
+	growRun(t, src, "out.bin")
+
+	got = nil
+	for i := range 9 {
+		b, _ := os.ReadFile(fmt.Sprintf("test/grow/out.bin_%d", i+1))
+		got = append(got, b...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("the parts were not started over after the source changed")
+	}
+	if !IsFile("test/grow/out.bin_01") {
+		t.Errorf("a file of the earlier layout was deleted")
+	}
+
+}
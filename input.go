@@ -41,8 +41,49 @@ type (
 		fs          *flag.FlagSet
 		part        int
 		size        byteSize
+		align       byteSize
+		recordSize  byteSize
+		rangeSpec   string
+		rangeList   string
+		rng         *RangeSpec
+		rngs        []RangeSpec
+		only        string
+		onlyIdx     []int
+		lines       bool
+		delimiter   string
+		delim       []byte
+		csv         bool
+		cdc         CDCParams
 		base        string
+		template    string
 		dir         []string
+		quota       []int64
+		dirWeight   string
+		weight      []float64
+		autoWeight  bool
+		layout      string
+		partMap     string
+		pmap        PartMap
+		lay         Layout
+		output      string
+		window      int
+		prealloc    bool
+		failover    bool
+		replicas    int
+		atomic      bool
+		cleanup     CleanupMode
+		fsync       FsyncPolicy
+		shard       Shard
+		repair      bool
+		noVerify    bool
+		pieces      string
+		wantDigest  bool
+		noDigest    bool
+		verifySig   string
+		pubKey      string
+		timestamp   bool
+		xattr       bool
+		leaseTTL    time.Duration
 		reset       FileResets
 		retry       int
 		timeout     time.Duration
@@ -110,25 +151,66 @@ func NewDLOptions() (*DLOptions, error) {
 
 	var ui func(*Download)
 
-	if opt.quiet || opt.force {
+	if opt.quiet || opt.force || opt.output != "" {
 		ui = nil
 	} else {
 		ui = ShowProgress
 	}
 
-	if opt.part > 1 || opt.size > 0 {
+	var shard *Shard
+	if opt.fs.Changed("shard") {
+		shard = &opt.shard
+		shard.TTL = max(opt.leaseTTL, time.Second)
+	}
+
+	if opt.part > 1 || opt.size > 0 || opt.rng != nil || opt.rngs != nil {
 		opt.header.h.Del("Range")
 	}
 
+	if opt.wantDigest && opt.header.h.Get("Want-Repr-Digest") == "" {
+		opt.header.h.Set("Want-Repr-Digest", WantReprDigest)
+	}
+
 	return &DLOptions{
-		URI:       uri,
-		BasePath:  opt.base,
-		DstDirs:   opt.dir,
-		PartCount: opt.part,
-		PartSize:  int64(opt.size),
-		ReDL:      opt.reset,
-		UI:        ui,
-		Force:     opt.force,
+		URI:          uri,
+		BasePath:     opt.base,
+		DstDirs:      opt.dir,
+		DirWeights:   opt.weight,
+		DirQuotas:    opt.quota,
+		AutoWeight:   opt.autoWeight,
+		Layout:       opt.lay,
+		PartMap:      opt.pmap,
+		PartCount:    opt.part,
+		PartSize:     int64(opt.size),
+		Align:        int64(opt.align),
+		RecordSize:   int64(opt.recordSize),
+		Range:        opt.rng,
+		Ranges:       opt.rngs,
+		Only:         opt.onlyIdx,
+		Delimiter:    opt.delim,
+		CSV:          opt.csv,
+		CDC:          opt.cdc,
+		NameTemplate: opt.template,
+		ReDL:         opt.reset,
+		UI:           ui,
+		Force:        opt.force,
+		Output:       opt.output,
+		Window:       opt.window,
+		Prealloc:     opt.prealloc,
+		Failover:     opt.failover,
+		Replicas:     opt.replicas,
+		Atomic:       opt.atomic,
+		Cleanup:      opt.cleanup,
+		Fsync:        opt.fsync,
+		Shard:        shard,
+		Repair:       opt.repair,
+		NoVerify:     opt.noVerify,
+		Pieces:       opt.pieces,
+		NoDigest:     opt.noDigest,
+		VerifySig:    opt.verifySig,
+		PubKey:       opt.pubKey,
+		Timestamping: opt.timestamp,
+		Xattr:        opt.xattr,
 		Mod: &IOMod{
 			Retry:       max(opt.retry, 0),
 			Timeout:     opt.timeout,
@@ -156,10 +238,75 @@ func (opt *options) init() {
 
 	fs.VarP(&opt.size, "size", "s", "")
 
+	fs.VarP(&opt.align, "align", "g", "")
+
+	fs.VarP(&opt.recordSize, "record-size", "e", "")
+
+	fs.StringVarP(&opt.rangeSpec, "range", "j", "", "")
+
+	fs.StringVarP(&opt.rangeList, "ranges", "J", "", "")
+
+	fs.StringVarP(&opt.only, "only", "O", "", "")
+
+	fs.BoolVarP(&opt.lines, "split-on-lines", "l", false, "")
+
+	fs.StringVarP(&opt.delimiter, "delimiter", "D", "", "")
+
+	fs.BoolVarP(&opt.csv, "csv", "C", false, "")
+
+	fs.VarP(&opt.cdc, "cdc", "K", "")
+
 	fs.StringVarP(&opt.base, "base", "b", "", "")
 
+	fs.StringVarP(&opt.template, "name-template", "n", "", "")
+
 	fs.StringSliceVarP(&opt.dir, "dir", "d", []string{""}, "")
 
+	fs.StringVarP(&opt.dirWeight, "dir-weight", "W", "", "")
+
+	fs.StringVarP(&opt.layout, "layout", "L", "contiguous", "")
+
+	fs.StringVarP(&opt.partMap, "map", "M", "", "")
+
+	fs.StringVarP(&opt.output, "output", "o", "", "")
+
+	fs.IntVarP(&opt.window, "window", "w", DefaultWindow, "")
+
+	fs.BoolVarP(&opt.prealloc, "preallocate", "a", false, "")
+
+	fs.BoolVarP(&opt.failover, "failover", "F", false, "")
+
+	fs.IntVarP(&opt.replicas, "replicas", "R", 1, "")
+
+	fs.BoolVarP(&opt.atomic, "atomic", "A", false, "")
+
+	fs.VarP(&opt.cleanup, "cleanup", "c", "")
+	flag.Lookup("cleanup").NoOptDefVal = "empty"
+
+	fs.VarP(&opt.fsync, "fsync", "S", "")
+
+	fs.VarP(&opt.shard, "shard", "k", "")
+
+	fs.DurationVarP(&opt.leaseTTL, "lease", "E", DefaultLeaseTTL, "")
+
+	fs.BoolVarP(&opt.repair, "repair", "m", false, "")
+
+	fs.BoolVarP(&opt.noVerify, "no-verify-resume", "N", false, "")
+
+	fs.StringVarP(&opt.pieces, "pieces", "P", "", "")
+
+	fs.BoolVarP(&opt.wantDigest, "want-digest", "y", false, "")
+
+	fs.BoolVarP(&opt.noDigest, "no-digest", "Y", false, "")
+
+	fs.StringVarP(&opt.verifySig, "verify-sig", "G", "", "")
+
+	fs.StringVarP(&opt.pubKey, "pubkey", "I", "", "")
+
+	fs.BoolVarP(&opt.timestamp, "timestamping", "T", false, "")
+
+	fs.BoolVarP(&opt.xattr, "xattr", "X", false, "")
+
 	fs.VarP(&opt.reset, "reset", "z", "")
 	flag.Lookup("reset").NoOptDefVal = "1,2,3"
 
@@ -197,6 +344,22 @@ func (opt *options) parse() (uri string, err error) {
 		return "", flag.ErrHelp
 	}
 
+	if err = opt.parseDirs(); err != nil {
+		return "", err
+	}
+
+	if err = opt.parseDelimiter(); err != nil {
+		return "", err
+	}
+
+	if err = opt.parseRanges(); err != nil {
+		return "", err
+	}
+
+	if err = opt.parseVerify(); err != nil {
+		return "", err
+	}
+
 	args := fs.Args()
 
 	switch len(args) {
@@ -214,6 +377,191 @@ func (opt *options) parse() (uri string, err error) {
 
 }
 
+func (opt *options) parseDirs() error {
+
+	for i, d := range opt.dir {
+		path, quota := splitDirQuota(d)
+		if quota == NoQuota {
+			continue
+		}
+		if opt.quota == nil {
+			opt.quota = make([]int64, len(opt.dir))
+		}
+		opt.dir[i] = path
+		opt.quota[i] = quota
+	}
+
+	switch strings.ToLower(strings.TrimSpace(opt.layout)) {
+	case "contiguous":
+		opt.lay = Contiguous
+	case "stripe":
+		opt.lay = Stripe
+	default:
+		return NewErr("%s: layout: %q", ErrParse, opt.layout)
+	}
+
+	if opt.partMap != "" {
+		if opt.fs.Changed("dir") || opt.fs.Changed("dir-weight") {
+			return NewErr("%s: --map cannot be used with --dir or --dir-weight", ErrArgs)
+		}
+		pm, err := ParsePartMap(opt.partMap)
+		if err != nil {
+			return err
+		}
+		opt.pmap = pm
+	}
+
+	switch w := strings.TrimSpace(opt.dirWeight); {
+	case w == "":
+		return nil
+	case strings.EqualFold(w, "auto"):
+		opt.autoWeight = true
+		return nil
+	default:
+		for _, v := range strings.Split(w, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || f < 0 {
+				return NewErr("%s: dir-weight: %q", ErrParse, v)
+			}
+			opt.weight = append(opt.weight, f)
+		}
+	}
+
+	if len(opt.weight) != len(opt.dir) {
+		return NewErr("%s: %d dir-weight values for %d directories",
+			ErrArgs, len(opt.weight), len(opt.dir))
+	}
+
+	return nil
+
+}
+
+func (opt *options) parseDelimiter() error {
+
+	aligned := opt.fs.Changed("align") || opt.fs.Changed("record-size")
+	if (opt.fs.Changed("align") && opt.align < 1) ||
+		(opt.fs.Changed("record-size") && opt.recordSize < 1) {
+		return NewErr("%s: --align and --record-size must be positive", ErrArgs)
+	}
+
+	if (opt.fs.Changed("cdc") || aligned) &&
+		(opt.csv || opt.lines || opt.fs.Changed("delimiter")) {
+		return NewErr("%s: --cdc, --align and --record-size cannot be used with --csv, --split-on-lines or --delimiter", ErrArgs)
+	}
+
+	if opt.fs.Changed("cdc") && aligned {
+		return NewErr("%s: --cdc cannot be used with --align or --record-size", ErrArgs)
+	}
+
+	switch {
+	case opt.fs.Changed("delimiter"):
+		delim, err := strconv.Unquote(`"` + opt.delimiter + `"`)
+		if err != nil || delim == "" {
+			return NewErr("%s: delimiter: %q", ErrParse, opt.delimiter)
+		}
+		opt.delim = []byte(delim)
+	case opt.lines:
+		opt.delim = []byte("\n")
+	}
+	return nil
+
+}
+
+func (opt *options) parseRanges() error {
+
+	fs := opt.fs
+
+	if fs.Changed("range") {
+		rs, err := ParseRangeSpec(opt.rangeSpec)
+		if err != nil {
+			return err
+		}
+		opt.rng = &rs
+	}
+
+	if fs.Changed("only") {
+		idx, err := ParseIndexList(opt.only)
+		if err != nil {
+			return err
+		}
+		opt.onlyIdx = idx
+	}
+
+	if fs.Changed("shard") {
+		for _, f := range []string{"only", "output", "timestamping"} {
+			if fs.Changed(f) {
+				return NewErr("%s: --shard cannot be used with --%s", ErrArgs, f)
+			}
+		}
+	}
+
+	if fs.Changed("only") && fs.Changed("timestamping") {
+		return NewErr("%s: --timestamping cannot be used with --only", ErrArgs)
+	}
+
+	if !fs.Changed("ranges") {
+		return nil
+	}
+
+	for _, f := range []string{"range", "part", "size", "align", "record-size",
+		"cdc", "csv", "split-on-lines", "delimiter"} {
+		if fs.Changed(f) {
+			return NewErr("%s: --ranges cannot be used with --%s", ErrArgs, f)
+		}
+	}
+
+	rss, err := ParseRangeList(opt.rangeList)
+	if err != nil {
+		return err
+	}
+	opt.rngs = rss
+
+	return nil
+
+}
+
+func (opt *options) parseVerify() error {
+
+	fs := opt.fs
+
+	if fs.Changed("pieces") && fs.Changed("output") { //streamed bytes cannot be fixed afterwards
+		return NewErr("%s: --pieces cannot be used with --output", ErrArgs)
+	}
+
+	if !fs.Changed("verify-sig") {
+		return nil
+	}
+
+	if opt.pubKey == "" {
+		return NewErr("%s: --verify-sig requires --pubkey", ErrArgs)
+	}
+
+	for _, f := range []string{"range", "ranges", "only", "shard", "csv"} { //the signature covers the whole file
+		if fs.Changed(f) {
+			return NewErr("%s: --verify-sig cannot be used with --%s", ErrArgs, f)
+		}
+	}
+
+	return nil
+
+}
+
+func splitDirQuota(dir string) (string, int64) {
+
+	i := strings.LastIndex(dir, ":")
+	if i < 0 {
+		return dir, NoQuota
+	}
+
+	var bs byteSize
+	if err := bs.Set(dir[i+1:]); err != nil || bs < 1 {
+		return dir, NoQuota //not a quota, e.g. a drive letter
+	}
+
+	return dir[:i], int64(bs)
+
+}
+
 func reqErrInfo(err error) error {
 
 	if err != nil {
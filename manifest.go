@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	Manifest struct {
+		URI      string         `json:"uri"`
+		DataSize int64          `json:"data_size"`
+		ETag     string         `json:"etag,omitempty"`
+		Modified string         `json:"last_modified,omitempty"`
+		Fsync    string         `json:"fsync,omitempty"`
+		Parts    []ManifestPart `json:"parts"`
+		Pieces   *PieceHashes   `json:"pieces,omitempty"`
+		Grown    bool           `json:"grown,omitempty"`
+		Template string         `json:"template,omitempty"`
+		path     string
+		keep     bool //keeps parts from earlier runs, as shards do
+	}
+
+	ManifestPart struct {
+		Path   string `json:"path"`
+		Start  int64  `json:"start"`
+		End    int64  `json:"end"`
+		Offset int64  `json:"offset"`
+		Synced int64  `json:"synced,omitempty"`
+		Head   int64  `json:"head,omitempty"`
+	}
+)
+
+const (
+	ManifestExt      = ".partdec"
+	manifestInterval = time.Second
+)
+
+func ManifestPath(base string, dirs []string) string {
+
+	dir := ""
+	if len(dirs) > 0 {
+		dir = dirs[0]
+	}
+
+	if dir == "" {
+		return filepath.Clean(base) + ManifestExt
+	}
+	return filepath.Clean(dir+PathSeparator+base) + ManifestExt
+
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+
+	m := &Manifest{path: path}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return m, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, NewErr("%s: %s: %w", ErrManifest, path, err)
+	}
+
+	return m, nil
+
+}
+
+func (m *Manifest) Save() error {
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := m.path + ".tmp"
+	if err := writeFileSync(tmp, b, m.durable()); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, m.path); err != nil { //replaces the old manifest atomically
+		return err
+	}
+
+	if m.durable() {
+		return syncDir(filepath.Dir(m.path))
+	}
+	return nil
+
+}
+
+func (m *Manifest) durable() bool {
+
+	return m.Fsync != "" && m.Fsync != "never"
+
+}
+
+func writeFileSync(path string, b []byte, durable bool) error {
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FilePerm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+
+	if durable {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+
+}
+
+func (m *Manifest) Part(path string, br ByteRange) (ManifestPart, bool) {
+
+	for _, mp := range m.Parts {
+		if mp.Path == path && mp.Start == br.Start && mp.End == br.End {
+			return mp, true
+		}
+	}
+	return ManifestPart{}, false
+
+}
+
+func (m *Manifest) Update(uri string, dataSize int64, fios FileIOs) {
+
+	m.URI = uri
+	m.DataSize = dataSize
+	fios = fios.All()
+
+	var kept []ManifestPart
+	if m.keep {
+		paths := make(map[string]bool)
+		for _, fio := range fios {
+			paths[fio.PullPath().Relative] = true
+		}
+		for _, mp := range m.Parts {
+			if !paths[mp.Path] {
+				kept = append(kept, mp)
+			}
+		}
+	}
+	m.Parts = make([]ManifestPart, len(fios))
+
+	for i, fio := range fios {
+		m.Parts[i] = ManifestPart{
+			Path:   fio.PullPath().Relative,
+			Start:  fio.Scope.Start,
+			End:    fio.Scope.End,
+			Offset: fio.PullOffset(),
+			Synced: fio.PullSynced(),
+			Head:   fio.headSize(),
+		}
+	}
+	m.Parts = append(m.Parts, kept...)
+
+}
+
+func (m *Manifest) discard() {
+
+	m.Parts, m.Pieces, m.Grown = nil, nil, false //the resets start the files over
+
+}
+
+func (fios FileIOs) ApplyManifest(m *Manifest) {
+
+	for _, fio := range fios.All() {
+
+		mp, ok := m.Part(fio.Path.Relative, fio.Scope)
+		if !ok {
+			continue
+		}
+
+		fio.Scope.Offset = min(max(mp.Offset, 0), fio.Scope.Offset)
+		if m.durable() {
+			fio.Scope.Offset = min(max(mp.Synced, 0), fio.Scope.Offset) //known-good data only
+			fio.Synced = fio.Scope.Offset
+		}
+
+	}
+
+}
+
+func (d *Download) SaveManifest() error {
+
+	if d.Manifest == nil {
+		return nil
+	}
+
+	size := d.DataSize
+	if d.Shard != nil {
+		size = d.Shard.total //the whole layout, not just this shard's share
+	}
+	d.Manifest.Update(d.URI, size, d.Files)
+	d.Manifest.Fsync = d.Fsync.String()
+	d.Manifest.Pieces = d.Pieces
+	d.Manifest.ETag = d.Validator.ETag
+	d.Manifest.Modified = d.Validator.lastModified()
+	if err := d.Manifest.Save(); err != nil {
+		return err
+	}
+
+	if d.Shard == nil {
+		d.Manifest.link()
+	}
+	return nil
+
+}
+
+func (d *Download) trackManifest() {
+
+	defer d.Flow.WG.Done()
+
+	tick := time.NewTicker(manifestInterval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			d.SaveManifest()
+		case <-d.Ctx.Done():
+			return
+		}
+	}
+
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type (
+	FileNamer func(i int, dir string) string
+)
+
+var (
+	placeholder = regexp.MustCompile(`\{([A-Za-z]+)(?::(0?)([0-9]+))?\}`)
+	nameToken   = regexp.MustCompile("\x00([a-z]+)\x00")
+)
+
+func NewFileNamer(base, tmpl string, brs []ByteRange) (FileNamer, error) {
+
+	count := len(brs)
+
+	if tmpl == "" {
+		pad := countDigits(count)
+		return func(i int, _ string) string {
+			if count <= 1 {
+				return base
+			}
+			return fmt.Sprintf("%s_%0*d", base, pad, i+1)
+		}, nil
+	}
+
+	unique := count <= 1
+	for _, m := range placeholder.FindAllStringSubmatch(tmpl, -1) {
+		switch m[1] {
+		case "index", "start", "end":
+			unique = true
+		case "base", "count", "dir":
+		default:
+			return nil, NewErr("%s: unknown placeholder: %s", ErrTemplate, m[0])
+		}
+	}
+
+	if !unique {
+		return nil, NewErr("%s: %q needs {index}, {start} or {end} for %d parts",
+			ErrTemplate, tmpl, count)
+	}
+
+	return func(i int, dir string) string {
+		return placeholder.ReplaceAllStringFunc(tmpl, func(ph string) string {
+
+			m := placeholder.FindStringSubmatch(ph)
+
+			var v string
+			switch m[1] {
+			case "base":
+				return base
+			case "dir":
+				return filepath.Base(dirOrDot(dir))
+			case "index":
+				v = strconv.Itoa(i + 1)
+			case "count":
+				v = strconv.Itoa(count)
+			case "start":
+				v = strconv.FormatInt(max(brs[i].Start, 0), 10)
+			case "end":
+				v = strconv.FormatInt(max(brs[i].End, 0), 10)
+			}
+
+			width, _ := strconv.Atoi(m[3])
+			pad := " "
+			if m[2] == "0" {
+				pad = "0"
+			}
+			if n := width - len(v); n > 0 {
+				v = strings.Repeat(pad, n) + v
+			}
+			return v
+
+		})
+	}, nil
+
+}
+
+func nameMatcher(base, tmpl, dir string) (string, *regexp.Regexp, []string) {
+
+	if tmpl == "" {
+		tmpl = "{base}_{index}" //the default naming, with any padding
+	}
+
+	marked := placeholder.ReplaceAllStringFunc(tmpl, func(ph string) string {
+		switch m := placeholder.FindStringSubmatch(ph); m[1] {
+		case "base":
+			return base
+		case "dir":
+			return filepath.Base(dirOrDot(dir))
+		default:
+			return "\x00" + m[1] + "\x00"
+		}
+	})
+
+	path := newRelativePath(marked, dir)
+	if strings.Contains(filepath.Dir(path), "\x00") {
+		return "", nil, nil //numbered directories are not scanned
+	}
+
+	var kinds []string
+	expr := nameToken.ReplaceAllStringFunc(regexp.QuoteMeta(path), func(tok string) string {
+		kinds = append(kinds, strings.Trim(tok, "\x00"))
+		return "( *[0-9]+)" //zero or space padded
+	})
+
+	re := regexp.MustCompile("^" + expr + "(?:" + regexp.QuoteMeta(TmpExt) + ")?$")
+	return filepath.Dir(path), re, kinds
+
+}
+
+func countDigits(n int) int {
+
+	count := 0
+	switch {
+	case n == 0:
+		return 1
+	case n < 0:
+		n = -n
+	}
+
+	for n > 0 {
+		n /= 10
+		count++
+	}
+	return count
+
+}
@@ -0,0 +1,437 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+type (
+	PieceHashes struct {
+		Size   int64    `json:"size"`
+		SHA256 []string `json:"sha256"`
+		total  int64
+	}
+
+	pieceVerifier struct {
+		ph    *PieceHashes
+		fio   *FileIO
+		h     hash.Hash
+		pos   int64
+		bad   []int
+		valid bool
+	}
+
+	metalink struct {
+		Files []struct {
+			Name   string `xml:"name,attr"`
+			Size   int64  `xml:"size"`
+			Pieces []struct {
+				Length int64    `xml:"length,attr"`
+				Type   string   `xml:"type,attr"`
+				Hashes []string `xml:"hash"`
+			} `xml:"pieces"`
+		} `xml:"file"`
+	}
+)
+
+const (
+	PiecesExt     = ".sha256-pieces"
+	pieceAttempts = 3
+)
+
+func LoadPieceHashes(path, origin string) (*PieceHashes, error) {
+
+	b, err := readPathOrURL(path, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("<")) {
+		return parseMetalink(b)
+	}
+	return parseSidecar(b)
+
+}
+
+func (opt *DLOptions) loadPieces(m *Manifest) (*PieceHashes, error) {
+
+	var ph *PieceHashes
+	var err error
+
+	switch {
+	case opt.Pieces != "":
+		if ph, err = LoadPieceHashes(opt.Pieces, opt.URI); err != nil {
+			return nil, err
+		}
+	case m != nil && m.Pieces != nil:
+		ph = m.Pieces //from an earlier run
+	default:
+		return nil, nil
+	}
+
+	if opt.srcSize < 1 {
+		return nil, NewErr("%s: %s", ErrPieces, ErrMultPart)
+	}
+	return ph, ph.Fit(opt.srcSize)
+
+}
+
+func readPathOrURL(path, origin string) ([]byte, error) {
+
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return fetchURL(path, origin)
+	}
+	return os.ReadFile(path)
+
+}
+
+func fetchURL(rawURL, origin string) ([]byte, error) {
+
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = http.Header{"User-Agent": []string{UserAgent}}
+	if o, err := url.Parse(origin); err == nil && o.Host == req.URL.Host {
+		req.Header = SharedHeader.Clone() //user headers, such as auth, only go to the same host
+		req.Header.Del("Range")
+	}
+
+	resp, err := (&http.Client{Transport: SharedTransport}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewErr("%s: %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+
+}
+
+func parseSidecar(b []byte) (*PieceHashes, error) {
+
+	ph := &PieceHashes{}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+
+	for sc.Scan() {
+
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#"):
+			k, v, ok := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+			if ok && strings.TrimSpace(k) == "piece-size" {
+				var bs byteSize
+				if err := bs.Set(strings.TrimSpace(v)); err != nil || bs < 1 {
+					return nil, NewErr("%s: piece-size: %q", ErrPieces, v)
+				}
+				ph.Size = int64(bs)
+			}
+			continue
+		}
+
+		sum := strings.ToLower(strings.Fields(line)[0]) //sha256sum output also works
+		ph.SHA256 = append(ph.SHA256, sum)
+
+	}
+
+	if ph.Size < 1 {
+		return nil, NewErr("%s: missing # piece-size: line", ErrPieces)
+	}
+	return ph, ph.check()
+
+}
+
+func parseMetalink(b []byte) (*PieceHashes, error) {
+
+	var ml metalink
+	if err := xml.Unmarshal(b, &ml); err != nil {
+		return nil, NewErr("%s: %s", ErrPieces, err)
+	}
+
+	for _, f := range ml.Files {
+		for _, p := range f.Pieces {
+			if p.Type != "sha-256" {
+				continue
+			}
+			ph := &PieceHashes{Size: p.Length}
+			for _, h := range p.Hashes {
+				ph.SHA256 = append(ph.SHA256, strings.ToLower(strings.TrimSpace(h)))
+			}
+			return ph, ph.check()
+		}
+	}
+
+	return nil, NewErr("%s: no sha-256 pieces in metalink", ErrPieces)
+
+}
+
+func (ph *PieceHashes) check() error {
+
+	if ph.Size < 1 || len(ph.SHA256) == 0 {
+		return NewErr("%s: empty piece list", ErrPieces)
+	}
+	for _, s := range ph.SHA256 {
+		if b, err := hex.DecodeString(s); err != nil || len(b) != sha256.Size {
+			return NewErr("%s: not a sha-256 digest: %q", ErrPieces, s)
+		}
+	}
+	return nil
+
+}
+
+func (ph *PieceHashes) Fit(sourceSize int64) error {
+
+	if n := (sourceSize + ph.Size - 1) / ph.Size; int64(len(ph.SHA256)) != n {
+		return NewErr("%s: %d pieces of %d for %d bytes, want %d",
+			ErrPieces, len(ph.SHA256), ph.Size, sourceSize, n)
+	}
+	ph.total = sourceSize
+	return nil
+
+}
+
+func (ph *PieceHashes) bounds(k int) (int64, int64) {
+
+	start := int64(k) * ph.Size
+	return start, min(start+ph.Size, ph.total) - 1
+
+}
+
+func (ph *PieceHashes) match(k int, b []byte) bool {
+
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]) == ph.SHA256[k]
+
+}
+
+func newPieceVerifier(ph *PieceHashes, fio *FileIO) *pieceVerifier {
+
+	if ph == nil {
+		return nil
+	}
+	pv := &pieceVerifier{ph: ph, fio: fio, h: sha256.New()}
+	pv.seek()
+	return pv
+
+}
+
+func (pv *pieceVerifier) inside(k int) bool {
+
+	start, end := pv.ph.bounds(k)
+	return start >= pv.fio.Scope.Start && end <= pv.fio.Scope.End
+
+}
+
+func (pv *pieceVerifier) seek() {
+
+	pv.pos = pv.fio.Scope.Start + pv.fio.PullOffset()
+	pv.h.Reset()
+	pv.valid = true
+
+	k := int(pv.pos / pv.ph.Size)
+	start, _ := pv.ph.bounds(k)
+	if pv.pos == start || !pv.inside(k) {
+		return
+	}
+
+	f, err := os.Open(pv.fio.PullPath().Relative) //the written start of the current piece
+	if err != nil {
+		pv.valid = false
+		return
+	}
+	defer f.Close()
+
+	off := pv.fio.headSize() + start - pv.fio.Scope.Start
+	if _, err := io.Copy(pv.h, io.NewSectionReader(f, off, pv.pos-start)); err != nil {
+		pv.valid = false
+	}
+
+}
+
+func (pv *pieceVerifier) feed(b []byte) {
+
+	for len(b) > 0 {
+
+		k := int(pv.pos / pv.ph.Size)
+		_, end := pv.ph.bounds(k)
+		n := min(int64(len(b)), end-pv.pos+1)
+
+		if pv.inside(k) {
+			pv.h.Write(b[:n])
+		}
+		pv.pos += n
+		b = b[n:]
+
+		if pv.pos == end+1 {
+			sum := hex.EncodeToString(pv.h.Sum(nil))
+			if pv.inside(k) && pv.valid && sum != pv.ph.SHA256[k] {
+				pv.bad = append(pv.bad, k)
+			}
+			pv.h.Reset()
+			pv.valid = true
+		}
+
+	}
+
+}
+
+func (d *Download) refetchPieces(rw *replicaWriter, bad []int) error {
+
+	p := d.sourceProber()
+	for _, k := range bad {
+		start, end := d.Pieces.bounds(k)
+		if err := d.refetchPiece(p, k, start, end, rw.fios); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stderr, "piece %d: %s re-downloaded\n", k+1, toEIC(end-start+1))
+	}
+	return nil
+
+}
+
+func (d *Download) refetchPiece(p *prober, k int, start, end int64, fios FileIOs) error {
+
+	for t := 0; t < pieceAttempts; t++ {
+
+		b, err := p.readAt(start, end-start+1)
+		if err != nil {
+			return err
+		}
+		if !d.Pieces.match(k, b) {
+			continue //corrupted again on the way in
+		}
+
+		for _, fio := range fios {
+			if err := fio.writePieceAt(b, start); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	}
+
+	return NewErr("%s: piece %d", ErrPieces, k+1)
+
+}
+
+func (fio *FileIO) writePieceAt(b []byte, start int64) error {
+
+	lo := max(start, fio.Scope.Start)
+	hi := min(start+int64(len(b))-1, fio.Scope.End)
+	if lo > hi {
+		return nil
+	}
+
+	f, err := os.OpenFile(fio.PullPath().Relative, os.O_WRONLY, FilePerm)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteAt(b[lo-start:hi-start+1], fio.headSize()+lo-fio.Scope.Start)
+	return JoinErr(err, f.Close())
+
+}
+
+func (d *Download) VerifySpanningPieces() error {
+
+	if d.Pieces == nil {
+		return nil
+	}
+
+	p := d.sourceProber()
+	for k := range d.Pieces.SHA256 {
+
+		start, end := d.Pieces.bounds(k)
+
+		var span FileIOs
+		for _, fio := range d.Files {
+			if fio.Scope.Start <= end && fio.Scope.End >= start {
+				span = append(span, fio)
+			}
+		}
+		if len(span) < 2 {
+			continue //already checked while written, or not downloaded here
+		}
+
+		b, ok := readPiece(span, start, end)
+		if !ok || d.Pieces.match(k, b) {
+			continue
+		}
+
+		var fios FileIOs
+		for _, fio := range span {
+			fios = append(fios, fio.Healthy()...)
+		}
+		if err := d.refetchPiece(p, k, start, end, fios); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stderr, "piece %d: %s re-downloaded\n", k+1, toEIC(end-start+1))
+
+	}
+
+	return nil
+
+}
+
+func readPiece(span FileIOs, start, end int64) ([]byte, bool) {
+
+	var buf bytes.Buffer
+	for _, fio := range span {
+
+		lead := fio.Lead()
+		if lead.PullState() != Completed {
+			return nil, false
+		}
+
+		lo := max(start, lead.Scope.Start)
+		hi := min(end, lead.Scope.End)
+
+		f, err := os.Open(lead.PullPath().Relative)
+		if err != nil {
+			return nil, false
+		}
+		_, err = io.Copy(&buf, io.NewSectionReader(f, lead.headSize()+lo-lead.Scope.Start, hi-lo+1))
+		f.Close()
+		if err != nil {
+			return nil, false
+		}
+
+	}
+
+	if int64(buf.Len()) != end-start+1 {
+		return nil, false //a gap in the layout, as with --only
+	}
+	return buf.Bytes(), true
+
+}
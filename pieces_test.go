@@ -0,0 +1,43 @@
+package partdec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestPieceVerifier(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := []byte("aaaabbbbccccdd")
+	sidecar := "# piece-size: 4\n"
+	for i := 0; i < len(data); i += 4 {
+		sum := sha256.Sum256(data[i:min(i+4, len(data))])
+		sidecar += fmt.Sprintf("%s  piece.%d\n", hex.EncodeToString(sum[:]), i/4)
+	}
+
+	ph, err := parseSidecar([]byte(sidecar))
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if err := ph.Fit(int64(len(data))); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	os.WriteFile("test/part", nil, 0644)
+	fio := &FileIO{Path: FilePath{Relative: "test/part"}, Scope: ByteRange{Start: 2, End: 13}}
+
+	pv := newPieceVerifier(ph, fio)
+	pv.feed([]byte("aabbXbcccc"))
+	pv.feed([]byte("dd"))
+
+	if !slices.Equal(pv.bad, []int{1}) {
+		t.Errorf("got bad pieces %v, want [1]", pv.bad) //piece 0 is only partly in scope
+	}
+
+}
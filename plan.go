@@ -0,0 +1,412 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	DstDir struct {
+		Path   string
+		Weight float64
+		Quota  int64
+	}
+
+	DirPlan []string
+
+	Layout uint8
+
+	PartMap map[int]string
+)
+
+const (
+	Contiguous Layout = iota
+	Stripe
+
+	NoQuota = 0
+)
+
+func NewDstDirs(paths []string, weights []float64, quotas []int64) ([]DstDir, error) {
+
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	if weights != nil && len(weights) != len(paths) {
+		return nil, NewErr("%s: %d weights for %d directories",
+			ErrArgs, len(weights), len(paths))
+	}
+
+	dds := make([]DstDir, len(paths))
+	for i, p := range paths {
+		dds[i] = DstDir{Path: p, Weight: 1, Quota: NoQuota}
+		if weights != nil {
+			dds[i].Weight = weights[i]
+		}
+		if i < len(quotas) {
+			dds[i].Quota = quotas[i]
+		}
+	}
+
+	return dds, nil
+
+}
+
+func AutoWeight(dds []DstDir) error {
+
+	for i := range dds {
+		free, err := freeSpace(dirOrDot(dds[i].Path))
+		if err != nil {
+			return err
+		}
+		if free < 0 {
+			return NewErr("%s: %s", ErrFreeSpace, dirOrDot(dds[i].Path))
+		}
+		dds[i].Weight = float64(free)
+		if dds[i].Quota == NoQuota || dds[i].Quota > free {
+			dds[i].Quota = free
+		}
+	}
+	return nil
+
+}
+
+func PlanDirs(brs []ByteRange, dds []DstDir, lay Layout) (DirPlan, error) {
+
+	var maxPartSize int64
+	for _, br := range brs {
+		maxPartSize = max(maxPartSize, (br.End-br.Start)+1)
+	}
+
+	weights := make([]float64, len(dds))
+	caps := make([]int, len(dds))
+	for i, dd := range dds {
+		weights[i] = max(dd.Weight, 0)
+		caps[i] = -1
+		if dd.Quota != NoQuota && maxPartSize > 0 {
+			caps[i] = int(dd.Quota / maxPartSize)
+		}
+	}
+
+	counts, err := weightedCounts(len(brs), weights, caps)
+	if err != nil {
+		return nil, err
+	}
+
+	dp := make(DirPlan, 0, len(brs))
+
+	switch lay {
+	case Stripe:
+		assigned := make([]int, len(dds))
+		for range brs {
+			x := -1
+			for i := range dds {
+				if assigned[i] >= counts[i] {
+					continue
+				}
+				if x < 0 || stripeLoad(assigned[i], counts[i]) < stripeLoad(assigned[x], counts[x]) {
+					x = i
+				}
+			}
+			assigned[x]++
+			dp = append(dp, dds[x].Path)
+		}
+	default:
+		for i, dd := range dds {
+			for range counts[i] {
+				dp = append(dp, dd.Path)
+			}
+		}
+	}
+
+	return dp, nil
+
+}
+
+func stripeLoad(assigned, count int) float64 {
+
+	return (float64(assigned) + 0.5) / float64(count) //spreads weighted dirs evenly
+
+}
+
+func (pm PartMap) Plan(partCount int) (DirPlan, error) {
+
+	dp := make(DirPlan, partCount)
+	for i := range dp {
+		dir, ok := pm[i]
+		if !ok {
+			return nil, NewErr("%s: part %d is not mapped", ErrMap, i+1)
+		}
+		dp[i] = dir
+	}
+
+	for i := range pm {
+		if i >= partCount {
+			return nil, NewErr("%s: part %d exceeds part count of %d", ErrMap, i+1, partCount)
+		}
+	}
+
+	return dp, nil
+
+}
+
+func (pm PartMap) Dirs() []string {
+
+	indexes := make([]int, 0, len(pm))
+	for i := range pm {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var dirs []string
+	seen := make(map[string]bool)
+	for _, i := range indexes {
+		if dir := pm[i]; !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+
+}
+
+func ParsePartMap(value string) (PartMap, error) {
+
+	pm := make(PartMap)
+
+	for _, entry := range strings.Split(value, ",") {
+
+		idx, dir, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || dir == "" {
+			return nil, NewErr("%s: map: %q", ErrParse, entry)
+		}
+
+		indexes, err := ParseIndexList(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range indexes {
+			if _, dup := pm[i]; dup {
+				return nil, NewErr("%s: part %d is mapped twice", ErrMap, i+1)
+			}
+			pm[i] = dir
+		}
+
+	}
+
+	return pm, nil
+
+}
+
+func ParseIndexList(value string) ([]int, error) {
+
+	var indexes []int
+
+	for _, v := range strings.Split(value, ",") {
+
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(v), "-")
+		if !isRange {
+			hi = lo
+		}
+
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 1 {
+			return nil, NewErr("%s: part index: %q", ErrParse, v)
+		}
+		last, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil || last < first {
+			return nil, NewErr("%s: part index: %q", ErrParse, v)
+		}
+
+		for i := first; i <= last; i++ {
+			indexes = append(indexes, i-1) //zero-based
+		}
+
+	}
+
+	return indexes, nil
+
+}
+
+func SelectParts(indexes []int, dp DirPlan, brs []ByteRange, name FileNamer) ([]int, DirPlan, []ByteRange, FileNamer, error) {
+
+	indexes = append([]int(nil), indexes...)
+	sort.Ints(indexes)
+
+	var sel []int
+	var sdp DirPlan
+	var sbrs []ByteRange
+	for x, i := range indexes {
+		if x > 0 && i == indexes[x-1] {
+			continue
+		}
+		if i < 0 || i >= len(dp) {
+			return nil, nil, nil, nil, NewErr("%s: part %d of %d", ErrSelect, i+1, len(dp))
+		}
+		sel = append(sel, i)
+		sdp = append(sdp, dp[i])
+		sbrs = append(sbrs, brs[i])
+	}
+
+	sname := func(x int, dir string) string {
+		return name(sel[x], dir) //names follow the full layout
+	}
+
+	return sel, sdp, sbrs, sname, nil
+
+}
+
+func FormatIndexList(indexes []int) string {
+
+	if len(indexes) == 0 {
+		return "none"
+	}
+
+	var b strings.Builder
+	for x := 0; x < len(indexes); {
+		y := x
+		for y+1 < len(indexes) && indexes[y+1] == indexes[y]+1 {
+			y++
+		}
+		if b.Len() > 0 {
+			b.WriteString(",")
+		}
+		switch {
+		case y == x:
+			fmt.Fprintf(&b, "%d", indexes[x]+1)
+		default:
+			fmt.Fprintf(&b, "%d-%d", indexes[x]+1, indexes[y]+1)
+		}
+		x = y + 1
+	}
+	return b.String()
+
+}
+
+func weightedCounts(total int, weights []float64, caps []int) ([]int, error) {
+
+	n := len(weights)
+	counts := make([]int, n)
+	fixed := make([]bool, n)
+
+	for {
+
+		left := total
+		wsum := 0.0
+		var free []int
+		for i := range n {
+			if fixed[i] {
+				left -= counts[i]
+				continue
+			}
+			wsum += weights[i]
+			free = append(free, i)
+		}
+
+		if left > 0 && wsum <= 0 {
+			return nil, NewErr("%s: %d parts left unassigned", ErrNoCapacity, left)
+		}
+
+		rems := make([]float64, n)
+		assigned := 0
+		for _, i := range free {
+			exact := 0.0
+			if wsum > 0 {
+				exact = float64(left) * weights[i] / wsum
+			}
+			counts[i] = int(exact)
+			rems[i] = exact - float64(counts[i])
+			assigned += counts[i]
+		}
+
+		var cand []int
+		for _, i := range free {
+			if weights[i] > 0 {
+				cand = append(cand, i)
+			}
+		}
+
+		//largest remainder, ties go to the earlier directory
+		sort.SliceStable(cand, func(a, b int) bool {
+			return rems[cand[a]] > rems[cand[b]]
+		})
+		for x := 0; assigned < left; x++ {
+			counts[cand[x%len(cand)]]++
+			assigned++
+		}
+
+		capped := false
+		for _, i := range free {
+			if caps[i] >= 0 && counts[i] > caps[i] {
+				counts[i] = caps[i]
+				fixed[i] = true
+				capped = true
+			}
+		}
+
+		if !capped {
+			return counts, nil
+		}
+
+	}
+
+}
+
+func (dp DirPlan) Explain(w io.Writer, brs []ByteRange, dds []DstDir) {
+
+	fmt.Fprintf(w, "%s\n", div)
+	for _, dd := range dds {
+
+		var indexes []int
+		var bytes int64
+
+		for i, dir := range dp {
+			if dir != dd.Path {
+				continue
+			}
+			indexes = append(indexes, i)
+			bytes += (brs[i].End - brs[i].Start) + 1
+		}
+
+		quota := "none"
+		if dd.Quota != NoQuota {
+			quota = toEIC(dd.Quota)
+		}
+
+		fmt.Fprintf(w, "%4d parts | %11s | quota %11s | %s <- %s\n",
+			len(indexes), toEIC(max(bytes, 0)), quota,
+			dirOrDot(dd.Path), FormatIndexList(indexes))
+
+	}
+	fmt.Fprintf(w, "%s\n", div)
+
+}
+
+func dirOrDot(dir string) string {
+
+	if dir == "" {
+		return "."
+	}
+	return dir
+
+}
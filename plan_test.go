@@ -0,0 +1,118 @@
+package partdec
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestPlanDirs(t *testing.T) {
+
+	brs := SplitByteRange(1000, 10, UnknownSize)
+
+	cases := []struct {
+		dds  []DstDir
+		want []int
+		fail bool
+	}{
+		{
+			dds:  []DstDir{{Path: "a", Weight: 1}, {Path: "b", Weight: 1}, {Path: "c", Weight: 1}},
+			want: []int{4, 3, 3},
+		},
+		{
+			dds:  []DstDir{{Path: "a", Weight: 3}, {Path: "b", Weight: 1}, {Path: "c", Weight: 1}},
+			want: []int{6, 2, 2},
+		},
+		{
+			dds:  []DstDir{{Path: "a", Weight: 1, Quota: 250}, {Path: "b", Weight: 1}},
+			want: []int{2, 8},
+		},
+		{
+			dds:  []DstDir{{Path: "a", Weight: 0}, {Path: "b", Weight: 1}},
+			want: []int{0, 10},
+		},
+		{
+			dds:  []DstDir{{Path: "a", Weight: 1, Quota: 300}, {Path: "b", Weight: 1, Quota: 300}},
+			fail: true,
+		},
+	}
+
+	for i, c := range cases {
+		dp, err := PlanDirs(brs, c.dds, Contiguous)
+		if c.fail {
+			if err == nil {
+				t.Errorf("case %d: error is expected", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("case %d: unexpected error: %s\n", i, err)
+			continue
+		}
+
+		got := make([]int, len(c.dds))
+		for _, dir := range dp {
+			got[slices.IndexFunc(c.dds, func(dd DstDir) bool { return dd.Path == dir })]++
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+
+}
+
+func TestStripe(t *testing.T) {
+
+	brs := SplitByteRange(1000, 6, UnknownSize)
+	dds := []DstDir{{Path: "a", Weight: 2}, {Path: "b", Weight: 1}}
+
+	dp, err := PlanDirs(brs, dds, Stripe)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if want := (DirPlan{"a", "b", "a", "a", "b", "a"}); !slices.Equal(dp, want) {
+		t.Errorf("got %v, want %v", dp, want)
+	}
+
+	pm, err := ParsePartMap("1-2:a, 3:b, 4-6:a")
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if dp, err = pm.Plan(6); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if want := (DirPlan{"a", "a", "b", "a", "a", "a"}); !slices.Equal(dp, want) {
+		t.Errorf("got %v, want %v", dp, want)
+	}
+
+	if _, err = pm.Plan(7); err == nil {
+		t.Errorf("error is expected")
+	}
+
+}
+
+func TestSelectParts(t *testing.T) {
+
+	brs := SplitByteRange(1000, 5, UnknownSize)
+	dp := DirPlan{"a", "b", "c", "d", "e"}
+	name := func(i int, dir string) string {
+		return dir + strconv.Itoa(i+1)
+	}
+
+	sel, sdp, sbrs, sname, err := SelectParts([]int{3, 1, 3}, dp, brs, name)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	if len(sel) != 2 || sdp[0] != "b" || sdp[1] != "d" || sbrs[1] != brs[3] {
+		t.Errorf("got %v %v %v", sel, sdp, sbrs)
+	}
+	if sname(1, sdp[1]) != "d4" {
+		t.Errorf("got name %q, want %q", sname(1, sdp[1]), "d4")
+	}
+
+	if _, _, _, _, err = SelectParts([]int{5}, dp, brs, name); err == nil {
+		t.Errorf("expected an error for an index past the last part")
+	}
+
+}
@@ -0,0 +1,26 @@
+package partdec
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPreallocateKeepsSize(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	f, err := os.Create("test/part")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer f.Close()
+
+	if err := preallocate(f, 1<<20); err != nil {
+		t.Skipf("fallocate: %s", err)
+	}
+	if info, _ := f.Stat(); info.Size() != 0 {
+		t.Errorf("got size %d, want 0: an unwritten part looks downloaded", info.Size())
+	}
+
+}
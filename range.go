@@ -0,0 +1,172 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"strconv"
+	"strings"
+)
+
+type (
+	RangeSpec struct {
+		Start, End int64 //UnknownSize for an open end; a suffix has no Start
+	}
+)
+
+func ParseRangeSpec(s string) (RangeSpec, error) {
+
+	lo, hi, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok || (lo == "" && hi == "") {
+		return RangeSpec{}, NewErr("%s: %q", ErrRange, s)
+	}
+
+	rs := RangeSpec{Start: UnknownSize, End: UnknownSize}
+	for _, x := range []struct {
+		str string
+		v   *int64
+	}{{lo, &rs.Start}, {hi, &rs.End}} {
+		if x.str == "" {
+			continue
+		}
+		var bs byteSize
+		if err := bs.Set(x.str); err != nil || bs < 0 {
+			return RangeSpec{}, NewErr("%s: %q", ErrRange, s)
+		}
+		*x.v = int64(bs)
+	}
+
+	if rs.Start == UnknownSize && rs.End < 1 {
+		return RangeSpec{}, NewErr("%s: %q", ErrRange, s)
+	}
+
+	return rs, nil
+
+}
+
+func ParseRangeList(s string) ([]RangeSpec, error) {
+
+	var rss []RangeSpec
+	for _, f := range strings.Split(s, ",") {
+		rs, err := ParseRangeSpec(f)
+		if err != nil {
+			return nil, err
+		}
+		rss = append(rss, rs)
+	}
+	return rss, nil
+
+}
+
+func (rs RangeSpec) Resolve(dataSize int64) (ByteRange, error) {
+
+	var br ByteRange
+	switch {
+	case rs.Start == UnknownSize:
+		br = ByteRange{Start: max(dataSize-rs.End, 0), End: dataSize - 1} //suffix
+	case rs.End == UnknownSize:
+		br = ByteRange{Start: rs.Start, End: dataSize - 1}
+	default:
+		br = ByteRange{Start: rs.Start, End: min(rs.End, dataSize-1)}
+	}
+
+	if br.Start >= dataSize || br.Start > br.End || (rs.End != UnknownSize && rs.Start > rs.End) {
+		return ByteRange{}, NewErr("%s: %s of %d", ErrRange, rs, dataSize)
+	}
+
+	return br, nil
+
+}
+
+func ResolveRanges(rss []RangeSpec, dataSize int64) ([]ByteRange, error) {
+
+	brs := make([]ByteRange, len(rss))
+	for i, rs := range rss {
+		br, err := rs.Resolve(dataSize)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && br.Start <= brs[i-1].End {
+			return nil, NewErr("%s: %s overlaps or precedes %s", ErrRange, rs, rss[i-1])
+		}
+		brs[i] = br
+	}
+	return brs, nil
+
+}
+
+func ShiftByteRange(brs []ByteRange, origin int64) {
+
+	if origin == 0 {
+		return
+	}
+	for i := range brs {
+		if brs[i].End == UnknownSize {
+			continue
+		}
+		brs[i].Start += origin
+		brs[i].End += origin
+	}
+
+}
+
+func (rs RangeSpec) String() string {
+
+	var lo, hi string
+	if rs.Start != UnknownSize {
+		lo = strconv.FormatInt(rs.Start, 10)
+	}
+	if rs.End != UnknownSize {
+		hi = strconv.FormatInt(rs.End, 10)
+	}
+	return lo + "-" + hi
+
+}
+
+func (opt *DLOptions) applyRange(dataSize int64, resumable bool) (int64, error) {
+
+	opt.srcSize = dataSize
+
+	if opt.Range == nil && opt.Ranges == nil {
+		return dataSize, nil
+	}
+
+	if !resumable || dataSize < 1 {
+		return 0, NewErr("%s: %s", ErrRange, ErrMultPart)
+	}
+
+	if opt.Ranges != nil {
+		brs, err := ResolveRanges(opt.Ranges, dataSize)
+		if err != nil {
+			return 0, err
+		}
+		var size int64
+		for _, br := range brs {
+			size += br.End - br.Start + 1
+		}
+		opt.parts = brs
+		opt.PartCount, opt.PartSize = len(brs), UnknownSize
+		return size, nil //the parts joined together are the logical file
+	}
+
+	br, err := opt.Range.Resolve(dataSize)
+	if err != nil {
+		return 0, err
+	}
+	opt.origin = br.Start
+	return br.End - br.Start + 1, nil
+
+}
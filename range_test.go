@@ -0,0 +1,36 @@
+package partdec
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveRanges(t *testing.T) {
+
+	for spec, want := range map[string]ByteRange{
+		"1K-2K":      {Start: 1024, End: 2048},
+		"4000-":      {Start: 4000, End: 9999},
+		"-100":       {Start: 9900, End: 9999},
+		"9000-20000": {Start: 9000, End: 9999},
+	} {
+		rs, err := ParseRangeSpec(spec)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s\n", spec, err)
+		}
+		br, err := rs.Resolve(10000)
+		if err != nil || br != want {
+			t.Errorf("%s: got %v (%v), want %v", spec, br, err, want)
+		}
+	}
+
+	for _, list := range []string{"0-99,50-150", "10000-", "-0", "5-1"} {
+		rss, err := ParseRangeList(list)
+		if err == nil {
+			_, err = ResolveRanges(rss, 10000)
+		}
+		if err == nil || !strings.HasPrefix(err.Error(), ErrRange.Error()) {
+			t.Errorf("%s: got %v, want %s", list, err, ErrRange)
+		}
+	}
+
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func LocateManifest(base string, dirs []string) (string, error) {
+
+	mp := ManifestPath(base, dirs)
+	for i := range max(len(dirs), 1) {
+
+		alt := ManifestPath(base, dirs[i:])
+		src, err := filepath.EvalSymlinks(alt) //a link left beside parts kept elsewhere
+		if err != nil || !IsFile(src) {
+			continue
+		}
+
+		if err := moveFile(src, mp); err != nil { //follows the first directory
+			return "", err
+		}
+		if i > 0 {
+			os.Remove(alt)
+		}
+		return mp, nil
+
+	}
+
+	return mp, nil
+
+}
+
+func (m *Manifest) link() {
+
+	abs, err := filepath.Abs(m.path)
+	if err != nil {
+		return
+	}
+
+	for _, mp := range m.Parts {
+
+		ptr := newRelativePath(filepath.Base(m.path), filepath.Dir(mp.Path))
+		if t, err := os.Readlink(ptr); err == nil && t != abs {
+			os.Remove(ptr) //the manifest has moved since
+		}
+		if _, err := os.Lstat(ptr); err == nil {
+			continue
+		}
+		os.Symlink(abs, ptr) //finds the manifest if its directory leaves the -d list
+
+	}
+
+}
+
+func (m *Manifest) Rebalance(dp DirPlan, paths []string, brs []ByteRange, dirs []string) error {
+
+	moved, kept := 0, 0
+	for i, p := range paths {
+
+		if IsFile(p) || IsFile(p+TmpExt) {
+			continue
+		}
+
+		src := m.find(p, brs[i], dirs)
+		if src == "" {
+			continue
+		}
+
+		dst := p
+		if filepath.Ext(src) == TmpExt {
+			dst += TmpExt
+		}
+
+		if dir := keepDir(src, dst, dirs); dir != "" && !hasRoom(dst, brs[i]) {
+			dp[i] = dir //a failed-over part stays where it is
+			kept++
+			continue
+		}
+
+		if err := moveFile(src, dst); err != nil {
+			return NewErr("%s: %s: %w", ErrRebalance, src, err)
+		}
+
+		for j := range m.Parts {
+			if m.Parts[j].Path == src {
+				m.Parts[j].Path = dst
+			}
+		}
+		moved++
+
+	}
+
+	if kept > 0 {
+		fmt.Fprintf(Stderr, "rebalanced: %d parts kept in place, their directories lack space\n", kept)
+	}
+	if moved == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(Stderr, "rebalanced: %d parts moved to their new directories\n", moved)
+	return m.Save()
+
+}
+
+func (m *Manifest) find(path string, br ByteRange, dirs []string) string {
+
+	var src string
+	var best int64 = -1
+	for _, mp := range m.Parts {
+		if mp.Start != br.Start || mp.End != br.End || mp.Path == path || !IsFile(mp.Path) {
+			continue
+		}
+		if mp.Offset > best {
+			src, best = mp.Path, mp.Offset //the most advanced replica
+		}
+	}
+	if src != "" {
+		return src
+	}
+
+	name := filepath.Base(path)
+	for _, dir := range dirs {
+		for _, alt := range []string{newRelativePath(name, dir), newRelativePath(name+TmpExt, dir)} {
+			if alt != path && alt != path+TmpExt && IsFile(alt) {
+				return alt //same name elsewhere, so the same part
+			}
+		}
+	}
+
+	return ""
+
+}
+
+func keepDir(src, dst string, dirs []string) string {
+
+	for _, dir := range dirs {
+		if newRelativePath(filepath.Base(dst), dir) == src {
+			return dir
+		}
+	}
+	return ""
+
+}
+
+func hasRoom(dst string, br ByteRange) bool {
+
+	free, err := freeSpace(dirOrDot(filepath.Dir(dst)))
+	return err != nil || free < 0 || free >= br.End-br.Start+1
+
+}
+
+func moveFile(src, dst string) error {
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if err := copyFilePrefix(src, dst, info.Size()); err != nil { //across devices
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+
+}
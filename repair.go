@@ -0,0 +1,183 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+const (
+	verifySize     = 4 * Kibi
+	verifySteps    = 8
+	repairAttempts = 3
+)
+
+func (d *Download) sourceProber() *prober {
+
+	return &prober{gendc: dataCasterOf(d.Type), uri: d.URI, size: math.MaxInt64} //absolute offsets
+
+}
+
+func (d *Download) RepairBroken() {
+
+	p := d.sourceProber()
+	for _, fio := range d.Files.All() {
+
+		if fio.State != Broken {
+			continue
+		}
+
+		if err := fio.repair(p, math.MaxInt64, d.Prealloc); err != nil {
+			fmt.Fprintf(Stderr, "%s: %s: %s\n", ErrRepair, fio.Path.Relative, err)
+			continue
+		}
+		if fio.State == Completed {
+			fmt.Fprintf(Stderr, "repaired: %s is complete\n", fio.Path.Relative)
+			continue
+		}
+		fmt.Fprintf(Stderr, "repaired: %s resumes from %d bytes\n",
+			fio.Path.Relative, fio.Scope.Offset)
+
+	}
+
+}
+
+func (d *Download) VerifyResume() {
+
+	p := d.sourceProber()
+	for _, fio := range d.Files.All() {
+
+		if fio.State != Resume {
+			continue
+		}
+
+		offset := fio.Scope.Offset
+		if err := fio.repair(p, offset, d.Prealloc); err != nil {
+			fmt.Fprintf(Stderr, "%s: %s: %s\n", ErrVerify, fio.Path.Relative, err)
+			continue //keeps the data when the source cannot be reached
+		}
+
+		if fio.Scope.Offset < offset {
+			fmt.Fprintf(Stderr, "verified: %s did not match the source, resumes from %d bytes instead of %d\n",
+				fio.Path.Relative, fio.Scope.Offset, offset)
+		}
+
+	}
+
+}
+
+func (fio *FileIO) repair(p *prober, offset int64, prealloc bool) error {
+
+	size, err := fio.DataSize()
+	if err != nil {
+		return err
+	}
+	off := min(size, offset, fio.ScopeSize())
+
+	verified, err := fio.verifyTail(p, off)
+	if err != nil {
+		return err
+	}
+
+	if err := fio.cut(fio.headSize()+verified, prealloc); err != nil { //drops the overflow too
+		return err
+	}
+
+	fio.PushOffset(verified)
+	switch verified {
+	case 0:
+		fio.PushState(New)
+	case fio.ScopeSize():
+		fio.PushState(Completed)
+	default:
+		fio.PushState(Resume)
+	}
+
+	return nil
+
+}
+
+func (fio *FileIO) cut(size int64, prealloc bool) error {
+
+	if err := os.Truncate(fio.Path.Relative, size); err != nil {
+		return err
+	}
+	if !prealloc {
+		return nil
+	}
+
+	f, err := os.OpenFile(fio.Path.Relative, os.O_WRONLY, FilePerm)
+	if err != nil {
+		return err
+	}
+	preallocate(f, fio.headSize()+fio.ScopeSize()) //truncating gave back the reserved space; best effort
+	return f.Close()
+
+}
+
+func (fio *FileIO) verifyTail(p *prober, off int64) (int64, error) {
+
+	f, err := os.Open(fio.Path.Relative)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	for step, gap := 0, int64(0); step < verifySteps; step++ {
+
+		end := off - gap
+		if end <= 0 {
+			break
+		}
+		n := min(verifySize, end)
+
+		local := make([]byte, n)
+		if _, err := f.ReadAt(local, fio.headSize()+end-n); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		remote, err := p.readAt(fio.Scope.Start+end-n, n)
+		if err != nil {
+			return 0, err
+		}
+
+		if bytes.Equal(local, remote) {
+			return end, nil
+		}
+		gap = max(gap*2, verifySize) //walks back further on each mismatch
+
+	}
+
+	return 0, nil //nothing could be verified, so start over
+
+}
+
+func (d *Download) repairWriter(rw *replicaWriter) error {
+
+	p := d.sourceProber()
+	for _, m := range rw.fios {
+		if err := m.repair(p, m.PullOffset(), d.Prealloc); err != nil {
+			return err
+		}
+	}
+	return rw.SetOffset()
+
+}
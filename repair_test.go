@@ -0,0 +1,96 @@
+package partdec
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"testing"
+)
+
+func TestRepair(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	src := bytes.Repeat([]byte("0123456789"), 4000)
+	os.WriteFile("test/src", src, 0644)
+
+	part := append([]byte{}, src[10000:30000]...)
+	copy(part[len(part)-100:], "corrupt") //near the end of its scope
+	part = append(part, "overflow"...)
+	os.WriteFile("test/part", part, 0644)
+
+	fio, err := NewFileIO("part", "test", os.O_WRONLY)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	defer fio.Close()
+	fio.Scope = ByteRange{Start: 10000, End: 29999}
+
+	p := &prober{gendc: dataCasterOf(File), uri: "test/src", size: math.MaxInt64}
+	if err := fio.repair(p, math.MaxInt64, false); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	if want := int64(20000 - verifySize); fio.Scope.Offset != want || fio.State != Resume {
+		t.Errorf("got offset %d in state %d, want %d in state %d",
+			fio.Scope.Offset, fio.State, want, Resume)
+	}
+
+	got, _ := os.ReadFile("test/part")
+	if !bytes.Equal(got, src[10000:10000+fio.Scope.Offset]) {
+		t.Errorf("kept bytes do not match the source")
+	}
+
+}
+
+func TestVerifyResume(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	src := bytes.Repeat([]byte("0123456789"), 4000)
+	os.WriteFile("test/src", src, 0644)
+
+	cases := []struct {
+		corrupt int   //where the part stops matching, -1 for nowhere
+		want    int64 //offset to resume from
+		state   FileState
+	}{
+		{-1, 20000, Resume},
+		{20000 - 100, 20000 - verifySize, Resume}, //walks back one window
+		{0, 0, New},
+	}
+
+	for i, c := range cases {
+
+		part := append([]byte{}, src[:20000]...)
+		if c.corrupt >= 0 {
+			copy(part[c.corrupt:], bytes.Repeat([]byte("x"), 20000-c.corrupt))
+		}
+		os.WriteFile("test/part", part, 0644)
+
+		fio, err := NewFileIO("part", "test", os.O_WRONLY)
+		if err != nil {
+			t.Fatalf("unexpected error: %s\n", err)
+		}
+		fio.Scope = ByteRange{Start: 0, End: 29999, Offset: 20000}
+		fio.State = Resume
+
+		d := &Download{Type: File, URI: "test/src", Files: FileIOs{fio}}
+		d.VerifyResume()
+		fio.Close()
+
+		if fio.Scope.Offset != c.want || fio.State != c.state {
+			t.Errorf("%d: got offset %d in state %d, want %d in state %d",
+				i, fio.Scope.Offset, fio.State, c.want, c.state)
+		}
+
+		got, _ := os.ReadFile("test/part")
+		if !bytes.Equal(got, src[:c.want]) {
+			t.Errorf("%d: got %d kept bytes, want the first %d of the source", i, len(got), c.want)
+		}
+
+	}
+
+}
@@ -0,0 +1,410 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type (
+	sibling struct {
+		path         string
+		index, count int
+		start, end   int64
+	}
+
+	oldPart struct {
+		ManifestPart
+		valid   int64
+		staged  string
+		claimed bool
+	}
+)
+
+const (
+	OldExt = ".partdec-old"
+)
+
+func partPaths(dp DirPlan, name FileNamer) []string {
+
+	paths := make([]string, len(dp))
+	for i := range dp {
+		paths[i] = newRelativePath(name(i, dp[i]), dp[i])
+	}
+	return paths
+
+}
+
+func (m *Manifest) Repartition(p *prober, paths []string, brs []ByteRange, atomic bool) error {
+
+	olds := m.oldParts()
+	if len(olds) == 0 || sameRanges(olds, brs) {
+		return nil
+	}
+
+	for _, o := range olds {
+		if same, checked, err := p.sameTail(o.ManifestPart); err != nil {
+			return err
+		} else if checked && !same {
+			fmt.Fprintf(Stderr, "%s: %s does not match the source\n", ErrSiblings, o.Path)
+			return nil //left for the usual state checks
+		}
+	}
+
+	m.Parts = m.Parts[:0]
+	for _, o := range olds {
+		o.staged = o.Path + OldExt
+		if err := os.Rename(o.Path, o.staged); err != nil { //frees the name for the new layout
+			return err
+		}
+		o.Path = o.staged
+		m.Parts = append(m.Parts, o.ManifestPart)
+	}
+	if err := m.Save(); err != nil { //an interrupted run picks up from the staged files
+		return err
+	}
+
+	var carved int64
+	var parts []ManifestPart
+	for i := len(brs) - 1; i >= 0; i-- { //a part starting where an old one did takes that file last
+
+		dst := paths[i]
+		if atomic {
+			dst += TmpExt
+		}
+
+		n, err := carve(dst, brs[i], olds, m.durable())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			parts = append(parts, ManifestPart{Path: dst, Start: brs[i].Start, End: brs[i].End, Offset: n, Synced: n})
+		}
+		carved += n
+
+	}
+
+	m.Parts = parts
+	if err := m.Save(); err != nil {
+		return err
+	}
+
+	for _, o := range olds {
+		if !o.claimed {
+			os.Remove(o.staged)
+		}
+	}
+
+	fmt.Fprintf(Stderr, "repartitioned: %s reused from %d existing parts\n",
+		toEIC(carved), len(olds))
+
+	return nil
+
+}
+
+func (m *Manifest) oldParts() []*oldPart {
+
+	best := make(map[ByteRange]*oldPart)
+	var olds []*oldPart
+
+	for _, mp := range m.Parts {
+
+		info, err := os.Stat(mp.Path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		o := &oldPart{ManifestPart: mp}
+		o.valid = max(min(mp.Offset, info.Size()-mp.Head, mp.End-mp.Start+1), 0)
+		olds = append(olds, o)
+
+		br := ByteRange{Start: mp.Start, End: mp.End}
+		if b, ok := best[br]; !ok || o.valid > b.valid {
+			best[br] = o //the most advanced replica
+		}
+
+	}
+
+	for _, o := range olds {
+		if best[ByteRange{Start: o.Start, End: o.End}] != o {
+			o.valid = 0 //other replicas are only staged and removed
+		}
+	}
+
+	return olds
+
+}
+
+func sameRanges(olds []*oldPart, brs []ByteRange) bool {
+
+	for _, o := range olds {
+		if !slices.Contains(brs, ByteRange{Start: o.Start, End: o.End}) {
+			return false
+		}
+	}
+	return true
+
+}
+
+func carve(dst string, br ByteRange, olds []*oldPart, durable bool) (int64, error) {
+
+	var w *os.File
+	pos := br.Start
+
+	for pos <= br.End {
+
+		var src *oldPart
+		for _, o := range olds {
+			if o.valid > 0 && !o.claimed && o.Start <= pos && pos < o.Start+o.valid {
+				src = o
+				break
+			}
+		}
+		if src == nil {
+			break //the rest is really missing
+		}
+
+		n := min(src.Start+src.valid, br.End+1) - pos
+
+		if w == nil && src.Start == br.Start && src.Head == 0 {
+			if err := os.Rename(src.staged, dst); err == nil { //split in place
+				src.claimed = true
+				if err := os.Truncate(dst, n); err != nil {
+					return 0, err
+				}
+				f, err := os.OpenFile(dst, os.O_WRONLY|os.O_APPEND, FilePerm)
+				if err != nil {
+					return 0, err
+				}
+				w = f
+				pos += n
+				continue
+			}
+		}
+
+		if w == nil {
+			f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, FilePerm)
+			if err != nil {
+				return 0, err
+			}
+			w = f
+		}
+
+		if err := copySection(w, src.staged, src.Head+pos-src.Start, n); err != nil {
+			w.Close()
+			return 0, err
+		}
+		pos += n
+
+	}
+
+	if w == nil {
+		return 0, nil
+	}
+
+	if durable {
+		if err := w.Sync(); err != nil {
+			w.Close()
+			return 0, err
+		}
+	}
+	return pos - br.Start, w.Close()
+
+}
+
+func copySection(w io.Writer, src string, off, n int64) error {
+
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, io.NewSectionReader(r, off, n))
+	return err
+
+}
+
+func ScanSiblings(base, tmpl string, dp DirPlan, dataSize int64) ([]ManifestPart, error) {
+
+	var sibs []sibling
+	scanned := make(map[string]bool)
+	for _, dir := range dp {
+
+		sdir, re, kinds := nameMatcher(base, tmpl, dir)
+		if re == nil || scanned[re.String()] {
+			continue
+		}
+		scanned[re.String()] = true
+
+		entries, _ := os.ReadDir(dirOrDot(sdir))
+		for _, e := range entries {
+
+			p := newRelativePath(e.Name(), sdir)
+			m := re.FindStringSubmatch(p)
+			if m == nil || e.IsDir() {
+				continue
+			}
+
+			sib := sibling{path: p, start: UnknownSize, end: UnknownSize}
+			for k, kind := range kinds {
+				v, err := strconv.ParseInt(strings.TrimSpace(m[k+1]), 10, 64)
+				if err != nil {
+					continue
+				}
+				switch kind {
+				case "index":
+					sib.index = int(v)
+				case "count":
+					sib.count = int(v)
+				case "start":
+					sib.start = v
+				case "end":
+					sib.end = v
+				}
+			}
+			sibs = append(sibs, sib)
+
+		}
+
+	}
+
+	if len(sibs) == 0 && tmpl == "" && IsFile(newRelativePath(base, dp[0])) {
+		sibs = append(sibs, sibling{path: newRelativePath(base, dp[0]), index: 1}) //an earlier single-part download
+	}
+
+	if len(sibs) == 0 || dataSize < 1 {
+		return nil, nil
+	}
+
+	if sibs[0].start != UnknownSize || sibs[0].end != UnknownSize {
+		return rangedSiblings(sibs, dataSize)
+	}
+	return indexedSiblings(sibs, dataSize)
+
+}
+
+func indexedSiblings(sibs []sibling, dataSize int64) ([]ManifestPart, error) {
+
+	found := make(map[int]string)
+	count := len(sibs)
+	for _, sib := range sibs {
+		if sib.index < 1 {
+			continue
+		}
+		if p, ok := found[sib.index]; ok {
+			return nil, NewErr("%w: %s and %s", ErrSiblings, p, sib.path) //such as _01 and _1
+		}
+		found[sib.index] = sib.path
+		if sib.count > 0 {
+			count = sib.count //named after the old part count
+		}
+	}
+
+	brs := SplitByteRange(dataSize, count, UnknownSize)
+
+	var mps []ManifestPart
+	for i, br := range brs {
+
+		p, ok := found[i+1]
+		if !ok {
+			return nil, nil //not the naming of a split by count
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			break
+		}
+
+		size := br.End - br.Start + 1
+		if info.Size() > size {
+			break
+		}
+		mps = append(mps, ManifestPart{Path: p, Start: br.Start, End: br.End, Offset: info.Size()})
+		if info.Size() < size {
+			break //later starts cannot be trusted past a partial part
+		}
+
+	}
+
+	return mps, nil
+
+}
+
+func rangedSiblings(sibs []sibling, dataSize int64) ([]ManifestPart, error) {
+
+	slices.SortFunc(sibs, func(a, b sibling) int {
+		return cmp.Compare(max(a.start, a.end), max(b.start, b.end))
+	})
+
+	var mps []ManifestPart
+	for i, sib := range sibs {
+
+		if i > 0 && max(sib.start, sib.end) == max(sibs[i-1].start, sibs[i-1].end) {
+			return nil, NewErr("%w: %s and %s", ErrSiblings, sibs[i-1].path, sib.path)
+		}
+
+		start, end := sib.start, sib.end
+		switch {
+		case start == UnknownSize && i == 0:
+			start = 0
+		case start == UnknownSize:
+			start = sibs[i-1].end + 1
+		}
+		switch {
+		case end != UnknownSize:
+		case i == len(sibs)-1:
+			end = dataSize - 1
+		default:
+			end = sibs[i+1].start - 1
+		}
+
+		info, err := os.Stat(sib.path)
+		if err != nil || start > end || end >= dataSize || info.Size() > end-start+1 {
+			continue //not a part of this source
+		}
+		mps = append(mps, ManifestPart{Path: sib.path, Start: start, End: end, Offset: info.Size()})
+
+	}
+
+	return mps, nil
+
+}
+
+func (p *prober) checkSiblings(mps []ManifestPart) ([]ManifestPart, error) {
+
+	var kept []ManifestPart
+	for _, mp := range mps {
+		same, checked, err := p.sameTail(mp)
+		switch {
+		case err != nil:
+			return nil, err
+		case checked && !same:
+			fmt.Fprintf(Stderr, "%s: %s does not match the source\n", ErrSiblings, mp.Path)
+			return kept, nil //the guessed layout is off from here on
+		}
+		kept = append(kept, mp)
+	}
+	return kept, nil
+
+}
@@ -0,0 +1,210 @@
+package partdec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestRepartition(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := []byte("0123456789abcdefghij")
+	size := int64(len(data))
+
+	m := &Manifest{path: "test/data.partdec"}
+	for i, br := range SplitByteRange(size, 2, UnknownSize) {
+		p := []string{"test/old_1", "test/old_2"}[i]
+		valid := br.End - br.Start + 1
+		if i == 1 {
+			valid = 4 //a partial part
+		}
+		os.WriteFile(p, data[br.Start:br.Start+valid], 0644)
+		m.Parts = append(m.Parts, ManifestPart{Path: p, Start: br.Start, End: br.End, Offset: valid})
+	}
+
+	paths := []string{"test/new_1", "test/new_2", "test/new_3", "test/new_4"}
+	brs := SplitByteRange(size, 4, UnknownSize)
+
+	os.WriteFile("test/src", bytes.ToUpper(data), 0644)
+	if err := m.Repartition(newProber(File, "test/src", size), paths, brs, false); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if IsFile(paths[0]) || !IsFile("test/old_1") {
+		t.Errorf("parts of a changed source were carved")
+	}
+
+	os.WriteFile("test/src", data, 0644)
+	if err := m.Repartition(newProber(File, "test/src", size), paths, brs, false); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	want := []string{"01234", "56789", "abcd", ""}
+	for i, p := range paths {
+		got, _ := os.ReadFile(p)
+		if !bytes.Equal(got, []byte(want[i])) {
+			t.Errorf("%s: got %q, want %q", p, got, want[i])
+		}
+	}
+
+	if _, err := os.Stat("test/old_1" + OldExt); !os.IsNotExist(err) {
+		t.Errorf("staged file left behind")
+	}
+
+}
+
+func TestRepartitionChangedSource(t *testing.T) {
+
+	os.MkdirAll("test/out", 0750)
+	defer os.RemoveAll("test/")
+
+	run := func(data []byte) error {
+		os.WriteFile("test/src", data, 0644)
+		newOpt := DLOptions{URI: "test/src", BasePath: "out", DstDirs: []string{"test/out"}, PartCount: 4, Mod: &IOMod{}}
+		d, err := NewDownload(&newOpt)
+		if err != nil {
+			return err
+		}
+		return d.Start()
+	}
+
+	first := bytes.Repeat([]byte("a"), 1000)
+	if err := run(first); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := run(bytes.Repeat([]byte("b"), 900)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for i := range 4 {
+		b, _ := os.ReadFile(fmt.Sprintf("test/out/out_%d", i+1))
+		if !bytes.Equal(b, first[:250]) {
+			t.Errorf("out_%d: bytes of the old source were carved into the new layout", i+1)
+		}
+	}
+
+}
+
+func TestRebalance(t *testing.T) {
+
+	os.MkdirAll("test/a", 0750)
+	os.MkdirAll("test/b", 0750)
+	defer os.RemoveAll("test/")
+
+	os.WriteFile("test/a/data_2", []byte("abc"), 0644)
+	os.WriteFile("test/a/data_3", []byte("xyz"), 0644)
+
+	m := &Manifest{path: "test/a/data.partdec", Parts: []ManifestPart{
+		{Path: "test/a/data_3", Start: 20, End: 29, Offset: 3},
+	}}
+
+	paths := []string{"test/a/data_1", "test/b/data_2", "test/b/data_3"}
+	brs := SplitByteRange(30, 3, UnknownSize)
+
+	if err := m.Rebalance(DirPlan{"test/a", "test/b", "test/b"}, paths, brs, []string{"test/a", "test/b"}); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	for p, want := range map[string]string{"test/b/data_2": "abc", "test/b/data_3": "xyz"} {
+		if got, _ := os.ReadFile(p); string(got) != want {
+			t.Errorf("%s: got %q, want %q", p, got, want)
+		}
+	}
+	if m.Parts[0].Path != "test/b/data_3" {
+		t.Errorf("manifest still points to %s", m.Parts[0].Path)
+	}
+
+}
+
+func TestRebalanceKeep(t *testing.T) {
+
+	os.MkdirAll("test/a", 0750)
+	os.MkdirAll("test/b", 0750)
+	defer os.RemoveAll("test/")
+
+	os.WriteFile("test/a/big_1", []byte("abc"), 0644)
+
+	m := &Manifest{path: "test/b/big.partdec", Parts: []ManifestPart{
+		{Path: "test/a/big_1", Start: 0, End: 1 << 60, Offset: 3},
+	}}
+	dp := DirPlan{"test/b"}
+
+	if err := m.Rebalance(dp, []string{"test/b/big_1"}, []ByteRange{{Start: 0, End: 1 << 60}}, []string{"test/b", "test/a"}); err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if dp[0] != "test/a" || !IsFile("test/a/big_1") {
+		t.Errorf("a part too big for its planned directory was moved")
+	}
+
+}
+
+func TestLocateManifest(t *testing.T) {
+
+	os.MkdirAll("test/a", 0750)
+	os.MkdirAll("test/b", 0750)
+	os.MkdirAll("test/c", 0750)
+	defer os.RemoveAll("test/")
+
+	m := &Manifest{path: "test/a/data" + ManifestExt, Parts: []ManifestPart{{Path: "test/b/data_1"}}}
+	m.Save()
+	m.link()
+
+	mp, err := LocateManifest("data", []string{"test/c", "test/b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if mp != "test/c/data"+ManifestExt || !IsFile(mp) || IsFile("test/a/data"+ManifestExt) {
+		t.Errorf("the manifest in a dropped directory was not followed")
+	}
+
+}
+
+func TestScanSiblings(t *testing.T) {
+
+	os.MkdirAll("test/a", 0750)
+	defer os.RemoveAll("test/")
+
+	for name, size := range map[string]int{
+		"d_1": 10, "d_2": 10, "d_3": 4,
+		"t.part01of04": 5, "t.part02of04": 5, "t.part03of04": 5, "t.part04of04": 2,
+		"r.0-9": 10, "r.10-19": 6,
+	} {
+		os.WriteFile("test/a/"+name, make([]byte, size), 0644)
+	}
+
+	dp := DirPlan{"test/a", "test/a"}
+	tests := []struct {
+		base, tmpl string
+		want       []ManifestPart
+	}{
+		{"d", "", []ManifestPart{
+			{Path: "test/a/d_1", Start: 0, End: 9, Offset: 10},
+			{Path: "test/a/d_2", Start: 10, End: 19, Offset: 10},
+			{Path: "test/a/d_3", Start: 20, End: 29, Offset: 4},
+		}},
+		{"t", "{base}.part{index:02}of{count}", []ManifestPart{
+			{Path: "test/a/t.part01of04", Start: 0, End: 7, Offset: 5},
+		}},
+		{"r", "{base}.{start}-{end}", []ManifestPart{
+			{Path: "test/a/r.0-9", Start: 0, End: 9, Offset: 10},
+			{Path: "test/a/r.10-19", Start: 10, End: 19, Offset: 6},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got, _ := ScanSiblings(tt.base, tt.tmpl, dp, 30); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.base, got, tt.want)
+		}
+	}
+
+	os.WriteFile("test/a/d_01", nil, 0644)
+	if _, err := ScanSiblings("d", "", dp, 30); !IsErr(err, ErrSiblings) {
+		t.Errorf("got %v, want a collision between d_1 and d_01", err)
+	}
+
+}
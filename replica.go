@@ -0,0 +1,265 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type (
+	replicaWriter struct {
+		fios FileIOs
+		pv   *pieceVerifier
+	}
+)
+
+func checkReplicas(dds []DstDir, replicas int) error {
+
+	if replicas <= 1 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, dd := range dds {
+		p := filepath.Clean(dirOrDot(dd.Path))
+		if seen[p] {
+			return NewErr("%s: duplicate destination directory: %s", ErrReplica, p)
+		}
+		seen[p] = true
+	}
+
+	if replicas > len(dds) {
+		return NewErr("%s: %d replicas for %d directories",
+			ErrReplica, replicas, len(dds))
+	}
+
+	return nil
+
+}
+
+func (fios FileIOs) BuildReplicas(dds []DstDir, replicas int) error {
+
+	for _, fio := range fios {
+
+		x := 0
+		for i, dd := range dds {
+			if dd.Path == fio.Path.DstDir {
+				x = i
+				break
+			}
+		}
+
+		for r := 1; r < replicas; r++ {
+			dir := dds[(x+r)%len(dds)].Path //circular indexing
+			rep, err := newPartFileIO(fio.Path.Base, dir, fio.Path.Temp)
+			if err != nil {
+				return err
+			}
+			fio.Replicas = append(fio.Replicas, rep)
+		}
+
+	}
+
+	return nil
+
+}
+
+func (fio *FileIO) Members() FileIOs {
+
+	return append(FileIOs{fio}, fio.Replicas...)
+
+}
+
+func (fios FileIOs) All() FileIOs {
+
+	all := make(FileIOs, 0, len(fios))
+	for _, fio := range fios {
+		all = append(all, fio.Members()...)
+	}
+	return all
+
+}
+
+func (fio *FileIO) Lead() *FileIO {
+
+	for _, m := range fio.Members() {
+		if m.PullState() != Broken {
+			return m
+		}
+	}
+	return fio
+
+}
+
+func (fio *FileIO) Healthy() FileIOs {
+
+	var fios FileIOs
+	for _, m := range fio.Members() {
+		if m.PullState() != Broken {
+			fios = append(fios, m)
+		}
+	}
+	return fios
+
+}
+
+func (fio *FileIO) RepairReplicas() error {
+
+	if len(fio.Replicas) == 0 {
+		return nil
+	}
+
+	var src *FileIO
+	for _, m := range fio.Healthy() {
+		if src == nil || m.PullOffset() > src.PullOffset() {
+			src = m //most advanced member
+		}
+	}
+
+	if src == nil || src.PullState() == Unknown {
+		return nil
+	}
+
+	var err error
+	for _, m := range fio.Members() {
+		if m == src || (m.PullState() != Broken && m.PullOffset() == src.PullOffset()) {
+			continue
+		}
+		if rerr := m.copyFrom(src); rerr != nil {
+			m.PushState(Broken)
+			err = JoinErr(err, rerr)
+			continue
+		}
+		m.PushState(src.PullState())
+	}
+
+	return err
+
+}
+
+func (fio *FileIO) copyFrom(src *FileIO) error {
+
+	start := fio.PullOffset()
+	if fio.PullState() == Broken || start > src.PullOffset() {
+		start = 0
+		if err := fio.Truncate(0); err != nil {
+			return err
+		}
+	}
+	fio.PushOffset(start)
+
+	if err := fio.SeekOffset(); err != nil {
+		return err
+	}
+
+	r, err := os.Open(src.PullPath().Relative)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	n := src.PullOffset() - start
+	_, err = io.Copy(fio, io.NewSectionReader(r, fio.headSize()+start, n))
+	return err
+
+}
+
+func (rw *replicaWriter) Write(b []byte) (int, error) {
+
+	var err error
+	var ok, failed FileIOs
+
+	for _, fio := range rw.fios {
+		n, werr := fio.Write(b)
+		if werr == nil && n < len(b) {
+			werr = io.ErrShortWrite
+		}
+		if werr != nil {
+			err = JoinErr(err, werr)
+			failed = append(failed, fio)
+			continue
+		}
+		ok = append(ok, fio)
+	}
+
+	if len(ok) == 0 {
+		return 0, err //every member failed, so the part fails as a whole
+	}
+
+	for _, fio := range failed {
+		fio.PushState(Broken)
+	}
+	rw.fios = ok
+
+	if rw.pv != nil {
+		rw.pv.feed(b)
+	}
+
+	return len(b), nil
+
+}
+
+func (rw *replicaWriter) Lead() *FileIO {
+
+	return rw.fios[0]
+
+}
+
+func (rw *replicaWriter) SetOffset() error {
+
+	offset := rw.Lead().PullOffset()
+	for _, fio := range rw.fios {
+		if err := fio.SetOffset(); err != nil {
+			return err
+		}
+		offset = min(offset, fio.PullOffset())
+	}
+
+	for _, fio := range rw.fios {
+		fio.PushOffset(offset) //keeps every member in line
+		if err := fio.SeekOffset(); err != nil {
+			return err
+		}
+	}
+
+	if rw.pv != nil {
+		rw.pv.seek()
+	}
+
+	return nil
+
+}
+
+func (rw *replicaWriter) PushState(fs FileState) {
+
+	for _, fio := range rw.fios {
+		fio.PushState(fs)
+	}
+
+}
+
+func (rw *replicaWriter) Close() error {
+
+	var err error
+	for _, fio := range rw.fios {
+		err = JoinErr(err, fio.Close())
+	}
+	return err
+
+}
@@ -0,0 +1,101 @@
+package partdec
+
+import (
+	"os"
+	"testing"
+)
+
+func newTestMembers(t *testing.T, dirs ...string) FileIOs {
+
+	var fios FileIOs
+	for _, dir := range dirs {
+		os.MkdirAll(dir, 0750)
+		fio, err := newPartFileIO("part", dir, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		fio.Scope = ByteRange{Start: 0, End: 9}
+		fios = append(fios, fio)
+	}
+	return fios
+
+}
+
+func TestReplicaWriter(t *testing.T) {
+
+	defer os.RemoveAll("test/")
+
+	fios := newTestMembers(t, "test/a", "test/b", "test/c")
+	rw := &replicaWriter{fios: fios}
+	defer rw.Close()
+
+	if _, err := rw.Write([]byte("hello")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, fio := range fios {
+		if fio.PullOffset() != 5 {
+			t.Errorf("%s: got offset %d, want 5", fio.Path.Relative, fio.PullOffset())
+		}
+	}
+
+	fios[1].File.Close() //fails every later write to b
+	if n, err := rw.Write([]byte("world")); err != nil || n != 5 {
+		t.Fatalf("got %d, %v, want 5, nil: the healthy members carry on", n, err)
+	}
+	if fios[1].PullState() != Broken {
+		t.Errorf("got state %d, want %d for the failed member", fios[1].PullState(), Broken)
+	}
+	if len(rw.fios) != 2 {
+		t.Errorf("got %d members, want 2 after one failed", len(rw.fios))
+	}
+
+	for _, p := range []string{"test/a/part", "test/c/part"} {
+		if b, _ := os.ReadFile(p); string(b) != "helloworld" {
+			t.Errorf("%s: got %q, want %q", p, b, "helloworld")
+		}
+	}
+
+	for _, fio := range rw.fios {
+		fio.File.Close()
+	}
+	if _, err := rw.Write([]byte("!")); err == nil {
+		t.Errorf("got no error when every member failed")
+	}
+
+}
+
+func TestRepairReplicas(t *testing.T) {
+
+	defer os.RemoveAll("test/")
+
+	fios := newTestMembers(t, "test/a", "test/b", "test/c")
+	lead, behind, broken := fios[0], fios[1], fios[2]
+	lead.Replicas = FileIOs{behind, broken}
+	defer func() {
+		for _, fio := range fios {
+			fio.Close()
+		}
+	}()
+
+	lead.Write([]byte("helloworld"))
+	lead.PushState(Completed)
+	behind.Write([]byte("hel"))
+	behind.PushState(Resume)
+	broken.Write([]byte("xxxxxxxx"))
+	broken.PushState(Broken)
+
+	if err := lead.RepairReplicas(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, fio := range fios {
+		if b, _ := os.ReadFile(fio.Path.Relative); string(b) != "helloworld" {
+			t.Errorf("%s: got %q, want %q", fio.Path.Relative, b, "helloworld")
+		}
+		if fio.PullOffset() != 10 || fio.PullState() != Completed {
+			t.Errorf("%s: got offset %d and state %d, want 10 and %d",
+				fio.Path.Relative, fio.PullOffset(), fio.PullState(), Completed)
+		}
+	}
+
+}
@@ -0,0 +1,395 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	Shard struct {
+		Index, Count int
+		TTL          time.Duration
+		Owner        string
+		started      time.Time
+		round        int
+		done         bool
+		total        int64
+	}
+
+	Lease struct {
+		Owner   string    `json:"owner"`
+		Expires time.Time `json:"expires"`
+		Done    bool      `json:"done,omitempty"`
+		Gen     int       `json:"gen"`
+		path    string
+		lost    bool
+		ctx     context.Context
+		stop    context.CancelFunc
+	}
+)
+
+const (
+	LeaseExt        = ".lease"
+	DefaultLeaseTTL = 30 * time.Second
+)
+
+func RunShard(opt *DLOptions) error {
+
+	s := opt.Shard
+	host, _ := os.Hostname()
+	s.Owner = host + ":" + s.String()
+	s.started = time.Now()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for s.round = 0; ; s.round++ {
+
+		o := *opt //each round splits and claims afresh
+		d, err := NewDownload(&o)
+		switch {
+		case IsErr(err, ErrNoClaim):
+		case err != nil:
+			return err
+		default:
+			if err = d.Start(); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(Stderr, "%s\n", s.Progress(ManifestPath(o.BasePath, o.DstDirs)))
+
+		if s.done {
+			return nil
+		}
+
+		if d == nil {
+			select {
+			case <-time.After(s.TTL / 2): //waits for a lease to expire
+			case <-ctx.Done():
+				return ErrCancel
+			}
+		}
+
+	}
+
+}
+
+func (s *Shard) Claim(paths []string) ([]int, []*Lease, error) {
+
+	var claimed []int
+	var leases []*Lease
+	done := 0
+	now := time.Now()
+
+	for i, p := range paths {
+
+		l, err := readLease(p + LeaseExt)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		own := i%s.Count == s.Index-1
+		takeover := s.round > 0 //other parts only after our own are done
+
+		var free bool
+		switch {
+		case l != nil && l.Done:
+			done++
+			continue
+		case l == nil:
+			free = own || (takeover && now.Sub(s.started) > s.TTL) //the owner never showed up
+		case l.Owner == s.Owner, now.After(l.Expires):
+			free = own || takeover
+		}
+		if !free {
+			continue
+		}
+
+		nl := &Lease{Owner: s.Owner, Expires: now.Add(s.TTL), path: p + LeaseExt}
+		if ok, err := nl.acquire(l, s.TTL); err != nil {
+			return nil, nil, err
+		} else if !ok {
+			continue //someone else got there first
+		}
+
+		claimed = append(claimed, i)
+		leases = append(leases, nl)
+
+	}
+
+	s.done = done == len(paths)
+	return claimed, leases, nil
+
+}
+
+func (s *Shard) ManifestPath(path string) string {
+
+	return fmt.Sprintf("%s.shard-%d-of-%d%s",
+		strings.TrimSuffix(path, ManifestExt), s.Index, s.Count, ManifestExt)
+
+}
+
+func (s *Shard) Progress(path string) string {
+
+	pattern := fmt.Sprintf("%s.shard-*-of-%d%s",
+		strings.TrimSuffix(path, ManifestExt), s.Count, ManifestExt)
+
+	mps, _ := filepath.Glob(pattern)
+
+	parts := make(map[string]ManifestPart)
+	for _, mp := range mps {
+		m, err := LoadManifest(mp)
+		if err != nil {
+			continue
+		}
+		for _, p := range m.Parts {
+			if q, ok := parts[p.Path]; !ok || p.Offset > q.Offset {
+				parts[p.Path] = p //the most advanced copy of a part wins
+			}
+		}
+	}
+
+	var bytes int64
+	var done int
+	for _, p := range parts {
+		size := p.End - p.Start + 1
+		bytes += min(p.Offset, size)
+		if p.Offset >= size {
+			done++
+		}
+	}
+
+	return fmt.Sprintf("shards: %d reporting, %d parts done, %s of %s",
+		len(mps), done, toEIC(bytes), toEIC(s.total))
+
+}
+
+func readLease(path string) (*Lease, error) {
+
+	b, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	l := &Lease{path: path}
+	if err := json.Unmarshal(b, l); err != nil {
+		return &Lease{path: path}, nil //a torn lease counts as expired
+	}
+	return l, nil
+
+}
+
+func (l *Lease) acquire(seen *Lease, ttl time.Duration) (bool, error) {
+
+	if seen == nil {
+		l.Gen = 1
+		b, err := json.Marshal(l)
+		if err != nil {
+			return false, err
+		}
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, FilePerm)
+		switch {
+		case os.IsExist(err):
+			return false, nil
+		case err != nil:
+			return false, err
+		}
+		_, err = f.Write(b)
+		return err == nil, JoinErr(err, f.Close())
+	}
+
+	unlock, ok := l.lock(ttl)
+	if !ok {
+		return false, nil //another shard is taking it over
+	}
+	defer unlock()
+
+	cur, err := readLease(l.path)
+	if err != nil || cur == nil || cur.Owner != seen.Owner || cur.Gen != seen.Gen {
+		return false, err //renewed or taken over since it was read
+	}
+
+	l.Gen = seen.Gen + 1
+	return true, l.save()
+
+}
+
+func (l *Lease) renew(ttl time.Duration) error {
+
+	unlock, ok := l.lock(ttl)
+	if !ok {
+		return nil //tries again on the next tick
+	}
+	defer unlock()
+
+	cur, err := readLease(l.path)
+	if err != nil {
+		return err
+	}
+	if cur == nil || cur.Owner != l.Owner || cur.Gen != l.Gen {
+		l.lost = true
+		if l.stop != nil {
+			l.stop() //the new owner writes the part from now on
+		}
+		return NewErr("%s: %s", ErrLease, l.path)
+	}
+	return l.save()
+
+}
+
+func (l *Lease) release(ttl time.Duration, done bool) {
+
+	unlock, ok := l.lock(ttl)
+	if !ok {
+		return //expires on its own
+	}
+	defer unlock()
+
+	if cur, err := readLease(l.path); err != nil || cur == nil || cur.Owner != l.Owner || cur.Gen != l.Gen {
+		return
+	}
+
+	if done {
+		l.Done = true
+		l.save()
+		return
+	}
+	os.Remove(l.path) //lets another shard take over at once
+
+}
+
+func (l *Lease) lock(ttl time.Duration) (func(), bool) {
+
+	path := l.path + ".lock"
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, FilePerm)
+	if err != nil {
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > ttl {
+			os.Remove(path) //left by a shard that died holding it
+		}
+		return nil, false
+	}
+	f.Close()
+
+	return func() { os.Remove(path) }, true
+
+}
+
+func (l *Lease) save() error {
+
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+	if err := writeFileSync(tmp, b, false); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, l.path)
+
+}
+
+func (d *Download) trackLeases() {
+
+	defer d.Flow.WG.Done()
+
+	tick := time.NewTicker(d.Shard.TTL / 3)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-tick.C:
+			d.renewLeases()
+		case <-d.Ctx.Done():
+			return
+		}
+	}
+
+}
+
+func (d *Download) renewLeases() {
+
+	for i, l := range d.Leases {
+		if l.lost {
+			continue
+		}
+		l.Done = d.Files[i].Lead().PullState() == Completed
+		l.Expires = time.Now().Add(d.Shard.TTL)
+		if err := l.renew(d.Shard.TTL); err != nil {
+			fmt.Fprintf(Stderr, "%s\n", err)
+		}
+	}
+
+}
+
+func (d *Download) releaseLeases() {
+
+	for i, l := range d.Leases {
+		if !l.lost {
+			l.release(d.Shard.TTL, i < len(d.Files) && d.Files[i].Lead().PullState() == Completed)
+		}
+	}
+
+}
+
+func (s *Shard) String() string {
+
+	if s.Count == 0 {
+		return ""
+	}
+	return strconv.Itoa(s.Index) + "/" + strconv.Itoa(s.Count)
+
+}
+
+func (s *Shard) Type() string {
+	return "Shard"
+}
+
+func (s *Shard) Set(value string) error {
+
+	i, n, ok := strings.Cut(value, "/")
+	if !ok {
+		return ErrParse
+	}
+
+	index, err := strconv.Atoi(strings.TrimSpace(i))
+	if err != nil {
+		return ErrParse
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(n))
+	if err != nil || count < 1 || index < 1 || index > count {
+		return ErrParse
+	}
+
+	s.Index, s.Count = index, count
+	return nil
+
+}
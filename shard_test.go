@@ -0,0 +1,93 @@
+package partdec
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestShardClaim(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	paths := []string{"test/p1", "test/p2", "test/p3", "test/p4"}
+
+	live := &Lease{Owner: "b", Expires: time.Now().Add(time.Hour), path: "test/p3" + LeaseExt}
+	live.save()
+	dead := &Lease{Owner: "b", Expires: time.Now().Add(-time.Hour), path: "test/p2" + LeaseExt}
+	dead.save()
+
+	s := &Shard{Index: 1, Count: 2, TTL: time.Hour, Owner: "a", started: time.Now()}
+
+	claimed, _, err := s.Claim(paths)
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+	if !slices.Equal(claimed, []int{0}) {
+		t.Errorf("round 0: got %v, want [0]", claimed) //p3 is ours but leased to a live owner
+	}
+
+	s.round++
+	claimed, _, _ = s.Claim(paths)
+	if !slices.Equal(claimed, []int{0, 1}) {
+		t.Errorf("round 1: got %v, want [0 1]", claimed)
+	}
+
+}
+
+func TestLeaseTakeover(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	path := "test/p1" + LeaseExt
+	dead := &Lease{Owner: "c", Expires: time.Now().Add(-time.Hour), Gen: 3, path: path}
+	dead.save()
+
+	seen, _ := readLease(path)
+	a := &Lease{Owner: "a", Expires: time.Now().Add(time.Hour), path: path}
+	b := &Lease{Owner: "b", Expires: time.Now().Add(time.Hour), path: path}
+
+	if ok, err := a.acquire(seen, time.Hour); !ok || err != nil {
+		t.Fatalf("first takeover failed: %v", err)
+	}
+	if ok, _ := b.acquire(seen, time.Hour); ok {
+		t.Errorf("a lease was taken over twice from the same read")
+	}
+
+	dead.ctx, dead.stop = context.WithCancel(context.Background())
+	a.ctx, a.stop = context.WithCancel(context.Background())
+
+	fio, err := newPartFileIO("p1", "test", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	e := &endpoint{c: dead.ctx, w: &replicaWriter{fios: FileIOs{fio}}}
+	stopped := make(chan struct{})
+	go func() {
+		e.watch()
+		close(stopped)
+	}()
+
+	if err := dead.renew(time.Hour); err == nil || !dead.lost {
+		t.Errorf("the old owner renewed a lease it lost")
+	}
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("the old owner's writer was not stopped")
+	}
+	if _, err := e.w.Write([]byte("x")); err == nil {
+		t.Errorf("the old owner keeps writing a part it lost")
+	}
+	if err := a.renew(time.Hour); err != nil || a.ctx.Err() != nil {
+		t.Errorf("the new owner was stopped: %v", err)
+	}
+	if cur, _ := readLease(path); cur.Owner != "a" || cur.Gen != 4 {
+		t.Errorf("got %s gen %d, want a gen 4", cur.Owner, cur.Gen)
+	}
+
+}
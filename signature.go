@@ -0,0 +1,307 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/ssh"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	Signature struct {
+		Format string
+		start  func() (sigCheck, error)
+		chk    sigCheck
+		tmp    string
+	}
+
+	sigCheck interface {
+		io.Writer
+		Verify() error
+	}
+
+	minisignCheck struct {
+		hash.Hash
+		pub ed25519.PublicKey
+		sig []byte
+	}
+
+	pipeCheck struct {
+		w    io.WriteCloser
+		err  error
+		wait func() error
+	}
+)
+
+const (
+	sshSigNamespace = "file"
+	sshSigPrincipal = "partdec"
+)
+
+var (
+	errSigFormat = NewErr("unrecognized signature format")
+	errSigKey    = NewErr("signature is not made by the given public key")
+	errSigBad    = NewErr("signature does not match the data")
+)
+
+func LoadSignature(sigPath, keyPath, origin string) (*Signature, error) {
+
+	sig, err := readPathOrURL(sigPath, origin)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := readPathOrURL(keyPath, origin)
+	if os.IsNotExist(err) {
+		key, err = []byte(keyPath), nil //the key itself
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s *Signature
+	text := string(bytes.TrimSpace(sig))
+	switch {
+	case strings.HasPrefix(text, "untrusted comment:"):
+		s, err = loadMinisign(text, string(key))
+	case strings.Contains(text, "-----BEGIN SSH SIGNATURE-----"):
+		s, err = loadSSHSig(sig, key)
+	case strings.Contains(text, "-----BEGIN PGP SIGNATURE-----") || len(sig) > 0 && sig[0]&0x80 != 0:
+		s, err = loadPGPSig(sig, key)
+	default:
+		err = errSigFormat
+	}
+
+	if err != nil {
+		return nil, NewErr("%s: %s", ErrSig, err)
+	}
+	return s, nil
+
+}
+
+func (s *Signature) Close() {
+
+	if s.chk != nil {
+		if pc, ok := s.chk.(*pipeCheck); ok {
+			pc.w.Close()
+			pc.wait()
+		}
+		s.chk = nil
+	}
+	if s.tmp != "" {
+		os.RemoveAll(s.tmp)
+	}
+
+}
+
+func loadMinisign(sig, key string) (*Signature, error) {
+
+	lines := strings.Split(sig, "\n")
+	if len(lines) < 4 {
+		return nil, errSigFormat
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 74 {
+		return nil, errSigFormat
+	}
+	trusted, ok := strings.CutPrefix(strings.TrimRight(lines[2], "\r"), "trusted comment: ")
+	if !ok {
+		return nil, errSigFormat
+	}
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, errSigFormat
+	}
+
+	var pub []byte
+	for _, line := range strings.Split(key, "\n") { //a key file or the key alone
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(line))
+		if err == nil && len(b) == 42 && string(b[:2]) == "Ed" {
+			pub = b
+		}
+	}
+	if pub == nil {
+		return nil, NewErr("minisign: invalid public key")
+	}
+
+	alg, keyID, body := string(raw[:2]), raw[2:10], raw[10:]
+	pk := ed25519.PublicKey(pub[10:])
+
+	switch {
+	case !bytes.Equal(keyID, pub[2:10]):
+		return nil, errSigKey
+	case alg != "ED":
+		return nil, NewErr("minisign: only prehashed signatures are supported")
+	case !ed25519.Verify(pk, append(append([]byte{}, body...), trusted...), global):
+		return nil, NewErr("minisign: trusted comment does not match")
+	}
+
+	return &Signature{
+		Format: "minisign",
+		start: func() (sigCheck, error) {
+			h, err := blake2b.New512(nil)
+			return &minisignCheck{Hash: h, pub: pk, sig: body}, err
+		},
+	}, nil
+
+}
+
+func (c *minisignCheck) Verify() error {
+
+	if !ed25519.Verify(c.pub, c.Sum(nil), c.sig) {
+		return errSigBad
+	}
+	return nil
+
+}
+
+func loadSSHSig(sig, key []byte) (*Signature, error) {
+
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		return nil, NewErr("ssh: %s", err)
+	}
+
+	tmp, err := os.MkdirTemp("", "partdec-sig-")
+	if err != nil {
+		return nil, err
+	}
+	s := &Signature{Format: "ssh", tmp: tmp}
+
+	sigPath, signers := filepath.Join(tmp, "sig"), filepath.Join(tmp, "allowed_signers")
+	err = JoinErr(os.WriteFile(sigPath, sig, FilePerm), os.WriteFile(signers, allowedSigners(key), FilePerm))
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	out, err := exec.Command("ssh-keygen", "-Y", "find-principals", "-s", sigPath, "-f", signers).Output()
+	principal, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if err != nil || principal == "" {
+		s.Close()
+		return nil, errSigKey
+	}
+
+	s.start = func() (sigCheck, error) {
+		cmd := exec.Command("ssh-keygen", "-Y", "verify", "-f", signers, "-I", principal,
+			"-n", sshSigNamespace, "-s", sigPath)
+		var msg bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &msg, &msg
+		w, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return &pipeCheck{w: w, wait: func() error {
+			if err := cmd.Wait(); err != nil {
+				line, _, _ := strings.Cut(strings.TrimSpace(msg.String()), "\n")
+				return NewErr("%s", line)
+			}
+			return nil
+		}}, nil
+	}
+
+	return s, nil
+
+}
+
+func allowedSigners(key []byte) []byte {
+
+	var b bytes.Buffer
+	for _, line := range strings.Split(string(key), "\n") { //allowed_signers lines are kept with their options
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pk, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err == nil && strings.Fields(line)[0] == pk.Type() {
+			line = sshSigPrincipal + " " + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pk)))
+		}
+		fmt.Fprintln(&b, line)
+	}
+	return b.Bytes()
+
+}
+
+func loadPGPSig(sig, key []byte) (*Signature, error) {
+
+	var keyring openpgp.EntityList
+	var err error
+	switch {
+	case bytes.Contains(key, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")):
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+	default:
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(key))
+	}
+	if err != nil {
+		return nil, NewErr("openpgp: %s", err)
+	}
+
+	check := openpgp.CheckDetachedSignature
+	if bytes.Contains(sig, []byte("-----BEGIN PGP SIGNATURE-----")) {
+		check = openpgp.CheckArmoredDetachedSignature
+	}
+
+	return &Signature{
+		Format: "openpgp",
+		start: func() (sigCheck, error) {
+			pr, pw := io.Pipe()
+			done := make(chan error, 1)
+			go func() {
+				_, err := check(keyring, pr, bytes.NewReader(sig), nil)
+				io.Copy(io.Discard, pr) //drains the rest after an early failure
+				if err != nil {
+					err = NewErr("%s", strings.TrimPrefix(err.Error(), "openpgp: "))
+				}
+				done <- err
+			}()
+			return &pipeCheck{w: pw, wait: func() error { return <-done }}, nil
+		},
+	}, nil
+
+}
+
+func (c *pipeCheck) Write(b []byte) (int, error) {
+
+	if c.err == nil {
+		_, c.err = c.w.Write(b)
+	}
+	return len(b), nil //a failed check must not fail the download itself
+
+}
+
+func (c *pipeCheck) Verify() error {
+
+	c.w.Close()
+	if err := c.wait(); err != nil {
+		return err
+	}
+	return c.err
+
+}
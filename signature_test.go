@@ -0,0 +1,60 @@
+package partdec
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"golang.org/x/crypto/blake2b"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMinisign(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	keyID := []byte("12345678")
+	data := []byte("some release artifact")
+	trusted := "timestamp:1700000000"
+
+	sum := blake2b.Sum512(data)
+	sig := ed25519.Sign(priv, sum[:])
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), trusted...))
+
+	b64 := base64.StdEncoding.EncodeToString
+	key := b64(append(append([]byte("Ed"), keyID...), pub...))
+	writeSig := func(comment string) {
+		os.WriteFile("test/data.minisig", []byte("untrusted comment: signature\n"+
+			b64(append(append([]byte("ED"), keyID...), sig...))+"\n"+
+			"trusted comment: "+comment+"\n"+b64(global)+"\n"), 0644)
+	}
+
+	writeSig(trusted)
+	s, err := LoadSignature("test/data.minisig", key, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	for _, tt := range []struct {
+		data string
+		ok   bool
+	}{
+		{string(data), true},
+		{string(data) + "!", false},
+	} {
+		chk, _ := s.start()
+		chk.Write([]byte(tt.data))
+		if err := chk.Verify(); (err == nil) != tt.ok {
+			t.Errorf("%q: got %v", tt.data, err)
+		}
+	}
+
+	writeSig("forged")
+	_, err = LoadSignature("test/data.minisig", key, "")
+	if err == nil || !strings.HasPrefix(err.Error(), ErrSig.Error()) {
+		t.Errorf("forged trusted comment: got %v", err)
+	}
+
+}
@@ -0,0 +1,38 @@
+//go:build windows
+
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+func freeSpace(dir string) (int64, error) {
+
+	p, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return UnknownSize, err
+	}
+
+	var avail uint64
+	if err := windows.GetDiskFreeSpaceEx(p, &avail, nil, nil); err != nil {
+		return UnknownSize, err
+	}
+	return int64(avail), nil
+
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+type (
+	prober struct {
+		gendc func(string) (DataCaster, error)
+		uri   string
+		size  int64
+		base  int64 //where the logical file starts in the source
+	}
+
+	probeReader struct {
+		io.Reader
+		dc DataCaster
+	}
+
+	csvScanner struct {
+		r      *bufio.Reader
+		pos    int64
+		quoted bool
+	}
+)
+
+const (
+	probeSize = 64 * Kibi
+)
+
+func newProber(t DLType, uri string, dataSize int64) *prober {
+
+	return &prober{gendc: dataCasterOf(t), uri: uri, size: dataSize}
+
+}
+
+func (p *prober) readAt(off, n int64) ([]byte, error) {
+
+	n = min(n, p.size-off)
+	if n <= 0 || off < 0 {
+		return nil, nil
+	}
+
+	dc, err := p.gendc(p.uri)
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+
+	r, err := dc.DataCast(ByteRange{Start: p.base + off, End: p.base + off + n - 1})
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(io.LimitReader(r, n))
+
+}
+
+func (p *prober) next(off int64, delim []byte) (int64, error) {
+
+	for off < p.size {
+
+		b, err := p.readAt(off, probeSize)
+		if err != nil {
+			return UnknownSize, err
+		}
+
+		if i := bytes.Index(b, delim); i >= 0 {
+			return off + int64(i), nil
+		}
+
+		if len(b) < len(delim) {
+			break
+		}
+		off += int64(len(b) - len(delim) + 1) //overlaps a delimiter cut in two
+
+	}
+
+	return UnknownSize, nil
+
+}
+
+func AlignToDelimiter(brs []ByteRange, delim []byte, p *prober) ([]ByteRange, error) {
+
+	if len(brs) < 2 || len(delim) == 0 || brs[0].End == UnknownSize {
+		return brs, nil
+	}
+
+	dataSize := brs[len(brs)-1].End + 1
+	aligned := make([]ByteRange, 0, len(brs))
+
+	var start int64
+	for _, br := range brs[:len(brs)-1] {
+
+		from := max(br.End+1-int64(len(delim)), start)
+
+		pos, err := p.next(from, delim)
+		if err != nil {
+			return nil, err
+		}
+
+		end := dataSize - 1
+		if pos >= 0 {
+			end = pos + int64(len(delim)) - 1
+		}
+
+		aligned = append(aligned, ByteRange{Start: start, End: end})
+		if start = end + 1; start >= dataSize {
+			break
+		}
+
+	}
+
+	if start < dataSize {
+		aligned = append(aligned, ByteRange{Start: start, End: dataSize - 1})
+	}
+
+	return aligned, nil
+
+}
+
+func (p *prober) open(off int64) (io.ReadCloser, error) {
+
+	dc, err := p.gendc(p.uri)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := dc.DataCast(ByteRange{Start: p.base + off, End: p.base + p.size - 1})
+	if err != nil {
+		dc.Close()
+		return nil, err
+	}
+
+	return &probeReader{Reader: r, dc: dc}, nil
+
+}
+
+func (pr *probeReader) Close() error {
+
+	return pr.dc.Close()
+
+}
+
+func (cs *csvScanner) next(from int64) (int64, error) {
+
+	for {
+		b, err := cs.r.ReadByte()
+		switch {
+		case err == io.EOF:
+			return UnknownSize, nil
+		case err != nil:
+			return UnknownSize, err
+		}
+
+		at := cs.pos
+		cs.pos++
+
+		switch b {
+		case '"':
+			cs.quoted = !cs.quoted //an escaped "" flips twice
+		case '\n':
+			if !cs.quoted && at >= from {
+				return at, nil
+			}
+		}
+	}
+
+}
+
+func AlignToCSV(brs []ByteRange, p *prober) ([]ByteRange, []byte, error) {
+
+	if len(brs) == 0 || brs[0].End == UnknownSize {
+		return brs, nil, nil
+	}
+
+	r, err := p.open(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	cs := &csvScanner{r: bufio.NewReaderSize(r, probeSize)}
+	dataSize := brs[len(brs)-1].End + 1
+
+	hdrEnd, err := cs.next(0)
+	if err != nil || hdrEnd < 0 || hdrEnd+1 >= dataSize {
+		return []ByteRange{{Start: 0, End: dataSize - 1}}, nil, err
+	}
+
+	header, err := p.readAt(0, hdrEnd+1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aligned := make([]ByteRange, 0, len(brs))
+
+	var start int64
+	for _, br := range brs[:len(brs)-1] {
+
+		pos, err := cs.next(max(br.End, hdrEnd+1))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		end := dataSize - 1
+		if pos >= 0 {
+			end = pos
+		}
+
+		aligned = append(aligned, ByteRange{Start: start, End: end})
+		if start = end + 1; start >= dataSize {
+			break
+		}
+
+	}
+
+	if start < dataSize {
+		aligned = append(aligned, ByteRange{Start: start, End: dataSize - 1})
+	}
+
+	return aligned, header, nil
+
+}
@@ -0,0 +1,150 @@
+package partdec
+
+import (
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAlignToDelimiter(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := "aaaa\nbb\ncccccccccccc\nd\n"
+	os.WriteFile("test/lines.txt", []byte(data), 0644)
+
+	size := int64(len(data))
+	brs := SplitByteRange(size, 4, UnknownSize)
+
+	aligned, err := AlignToDelimiter(brs, []byte("\n"), newProber(File, "test/lines.txt", size))
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	var parts []string
+	for _, br := range aligned {
+		parts = append(parts, data[br.Start:br.End+1])
+	}
+
+	want := []string{"aaaa\nbb\n", "cccccccccccc\n", "d\n"}
+	if strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", parts, want)
+	}
+
+}
+
+func TestAlignToCSV(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := "id,text\n1,\"a\nb\nc\"\n2,x\n3,\"y\"\"\nz\"\n"
+	os.WriteFile("test/data.csv", []byte(data), 0644)
+
+	size := int64(len(data))
+	brs := SplitByteRange(size, 3, UnknownSize)
+
+	aligned, header, err := AlignToCSV(brs, newProber(File, "test/data.csv", size))
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n", err)
+	}
+
+	if string(header) != "id,text\n" {
+		t.Errorf("got header %q", header)
+	}
+
+	var parts []string
+	for _, br := range aligned {
+		parts = append(parts, data[br.Start:br.End+1])
+	}
+
+	want := []string{"id,text\n1,\"a\nb\nc\"\n", "2,x\n", "3,\"y\"\"\nz\"\n"}
+	if strings.Join(parts, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", parts, want)
+	}
+
+}
+
+func TestSplitByContent(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	edited := append(append(append([]byte{}, data[:1000]...), "inserted"...), data[1000:]...)
+
+	cp := CDCParams{Min: 4 << 10, Avg: 16 << 10, Max: 64 << 10}
+
+	split := func(b []byte) map[string]bool {
+		os.WriteFile("test/data.bin", b, 0644)
+		size := int64(len(b))
+		brs, err := SplitByContent(size, cp, newProber(File, "test/data.bin", size))
+		if err != nil {
+			t.Fatalf("unexpected error: %s\n", err)
+		}
+		if brs[0].Start != 0 || brs[len(brs)-1].End != size-1 {
+			t.Fatalf("parts do not cover the data: %v", brs)
+		}
+		chunks := make(map[string]bool)
+		for i, br := range brs {
+			n := br.End - br.Start + 1
+			if n > cp.Max || (n < cp.Min && i < len(brs)-1) {
+				t.Errorf("part %d out of bounds: %d", i, n)
+			}
+			chunks[string(b[br.Start:br.End+1])] = true
+		}
+		return chunks
+	}
+
+	before, after := split(data), split(edited)
+
+	changed := 0
+	for c := range after {
+		if !before[c] {
+			changed++
+		}
+	}
+	if changed > 2 {
+		t.Errorf("an insert changed %d of %d parts", changed, len(after))
+	}
+
+}
+
+func TestSplitAlignedByteRange(t *testing.T) {
+
+	unit := AlignUnit(4096, 100)
+	if unit != 102400 {
+		t.Fatalf("got unit %d, want 102400", unit)
+	}
+
+	size := int64(10*unit + 123)
+	brs := SplitAlignedByteRange(size, 3, UnknownSize, unit)
+
+	for i, br := range brs {
+		if br.Start%unit != 0 {
+			t.Errorf("part %d starts at %d, not a multiple of %d", i, br.Start, unit)
+		}
+	}
+
+	want := []ByteRange{
+		{Start: 0, End: 3*unit - 1},
+		{Start: 3 * unit, End: 6*unit - 1},
+		{Start: 6 * unit, End: size - 1},
+	}
+	for i := range want {
+		if brs[i] != want[i] {
+			t.Errorf("part %d: got %v, want %v", i, brs[i], want[i])
+		}
+	}
+
+	origin := unit + 1000 //a --range that starts mid-unit
+	for i, br := range AlignByteRange(brs, origin, unit)[1:] {
+		if (origin+br.Start)%unit != 0 {
+			t.Errorf("part %d starts at source offset %d, not a multiple of %d", i+2, origin+br.Start, unit)
+		}
+	}
+
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+type (
+	Stream struct {
+		io.WriteCloser
+		Window *FlowControl
+		TmpDir string
+		Path   string
+		tee    io.Writer
+	}
+)
+
+const (
+	StdoutPath    = "-"
+	DefaultWindow = 8
+
+	streamPoll = 50 * time.Millisecond
+)
+
+func NewStream(path string, window int) (*Stream, error) {
+
+	var w io.WriteCloser
+
+	switch path {
+	case StdoutPath:
+		w = os.Stdout
+	default:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+	}
+
+	tmp, err := os.MkdirTemp("", "partdec-")
+	if err != nil {
+		if path != StdoutPath {
+			w.Close()
+			os.Remove(path)
+		}
+		return nil, err
+	}
+
+	if window < 1 {
+		window = DefaultWindow
+	}
+
+	return &Stream{
+		WriteCloser: w,
+		Window:      NewFlowControl(window),
+		TmpDir:      tmp,
+		Path:        path,
+	}, nil
+
+}
+
+func (st *Stream) Write(b []byte) (int, error) {
+
+	n, err := st.WriteCloser.Write(b)
+	if st.tee != nil {
+		if _, terr := st.tee.Write(b[:n]); terr != nil && err == nil {
+			err = terr //the checks would read short input otherwise
+		}
+	}
+	return n, err
+
+}
+
+func (d *Download) streamAll(errCh chan<- error) {
+
+	defer d.Flow.WG.Done()
+
+	for _, fio := range d.Files {
+		if err := d.stream(fio); err != nil {
+			if !IsErr(err, d.Ctx.Err()) {
+				err = JoinErr(err, ErrAbort)
+			}
+			d.Stop()
+			errCh <- err
+			return
+		}
+		d.Stream.Window.Release()
+	}
+
+	errCh <- nil
+
+}
+
+func (d *Download) stream(fio *FileIO) error {
+
+	f, err := os.Open(fio.Path.Relative)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(fio.Path.Relative)
+	defer f.Close()
+
+	for {
+		state := fio.PullState() //pulled before copy to not miss the tail
+
+		if _, err := io.Copy(d.Stream, f); err != nil {
+			return err
+		}
+
+		switch state {
+		case Completed:
+			return nil
+		case Broken:
+			return NewErr("%s: %s", ErrBroken, fio.Path.Relative)
+		}
+
+		select {
+		case <-time.After(streamPoll):
+		case <-d.Ctx.Done():
+			return d.Ctx.Err()
+		}
+	}
+
+}
+
+func (st *Stream) Close() error {
+
+	defer os.RemoveAll(st.TmpDir)
+
+	if st.WriteCloser == os.Stdout {
+		return nil
+	}
+	return st.WriteCloser.Close()
+
+}
@@ -0,0 +1,35 @@
+package partdec
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, errors.New("tee failed")
+}
+
+func TestStreamTeeErr(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	st, err := NewStream("test/out", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer st.Close()
+
+	if _, err := st.Write([]byte("data")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	st.tee = failWriter{}
+	if _, err := st.Write([]byte("data")); err == nil {
+		t.Errorf("got no error when the checks could not read the output")
+	}
+
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2024 Carlo Jay I. Jacaba
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package partdec
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	Validator struct {
+		ETag     string
+		Modified time.Time
+	}
+)
+
+const (
+	OriginXattr = "user.xdg.origin.url"
+)
+
+func NewValidator(hdr http.Header) Validator {
+
+	v := Validator{ETag: hdr.Get("ETag")}
+	if t, err := http.ParseTime(hdr.Get("Last-Modified")); err == nil {
+		v.Modified = t
+	}
+	return v
+
+}
+
+func (v Validator) lastModified() string {
+
+	if v.Modified.IsZero() {
+		return ""
+	}
+	return v.Modified.UTC().Format(http.TimeFormat)
+
+}
+
+func (v Validator) Match(m *Manifest, dataSize int64) (same, known bool) {
+
+	switch {
+	case m == nil:
+		return false, false
+	case v.ETag != "" && m.ETag != "":
+		return v.ETag == m.ETag && dataSize == m.DataSize, true
+	case v.lastModified() != "" && m.Modified != "":
+		return v.lastModified() == m.Modified && dataSize == m.DataSize, true
+	}
+	return false, false
+
+}
+
+func (m *Manifest) complete(paths []string, brs []ByteRange) bool {
+
+	for i, path := range paths {
+		mp, ok := m.Part(path, brs[i])
+		if !ok || mp.Offset <= mp.End-mp.Start {
+			return false
+		}
+		if _, err := os.Stat(path); err != nil {
+			return false
+		}
+	}
+	return len(paths) > 0
+
+}
+
+func stamped(paths []string, modified time.Time) bool {
+
+	if modified.IsZero() {
+		return false
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modified) {
+			return false
+		}
+	}
+	return len(paths) > 0
+
+}
+
+func (opt *DLOptions) checkTimestamp(d *Download, paths []string, brs []ByteRange) error {
+
+	same, known := d.Validator.Match(d.Manifest, d.DataSize)
+
+	switch {
+	case known && same && d.Manifest.complete(paths, brs), !known && stamped(paths, d.Validator.Modified):
+		return NewErr("%w: %s", ErrNotModified, filepath.Base(opt.BasePath))
+	case known && same:
+		return nil //resumes what is already there
+	case known:
+		fmt.Fprintf(Stderr, "%s: %s\n", ErrModified, filepath.Base(opt.BasePath))
+	}
+
+	opt.ReDL = FileResets{Resume: true, Completed: true, Broken: true}
+	if d.Manifest != nil {
+		d.Manifest.discard()
+	}
+	return nil
+
+}
+
+func (d *Download) Stamp() {
+
+	if !d.Timestamping && !d.Xattr {
+		return
+	}
+
+	var paths []string
+	switch {
+	case d.Stream != nil:
+		if d.Stream.Path != StdoutPath {
+			paths = append(paths, d.Stream.Path)
+		}
+	default:
+		for _, fio := range d.Files.All() {
+			if fio.PullState() == Completed {
+				paths = append(paths, fio.PullPath().Relative)
+			}
+		}
+	}
+
+	xattr := d.Xattr
+	for _, path := range paths { //the data is already in place, so failures only warn
+		if d.Timestamping && !d.Validator.Modified.IsZero() {
+			if err := os.Chtimes(path, time.Now(), d.Validator.Modified); err != nil {
+				fmt.Fprintf(Stderr, "%s\n", err)
+			}
+		}
+		if !xattr {
+			continue
+		}
+		switch err := setXattr(path, OriginXattr, originURL(d.URI)); {
+		case IsErr(err, ErrXattr):
+			fmt.Fprintf(Stderr, "%s\n", err)
+			xattr = false //the same for every part
+		case err != nil:
+			fmt.Fprintf(Stderr, "%s\n", err)
+		}
+	}
+
+}
+
+func originURL(uri string) string {
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		if abs, err := filepath.Abs(uri); err == nil {
+			return "file://" + filepath.ToSlash(abs)
+		}
+		return uri
+	}
+	u.User = nil //never records credentials
+	return u.String()
+
+}
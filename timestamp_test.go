@@ -0,0 +1,88 @@
+package partdec
+
+import (
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestValidatorMatch(t *testing.T) {
+
+	v := NewValidator(http.Header{
+		"Etag":          {`"abc"`},
+		"Last-Modified": {"Tue, 02 Jan 2024 03:04:05 GMT"},
+	})
+
+	tests := []struct {
+		m     *Manifest
+		same  bool
+		known bool
+	}{
+		{nil, false, false},
+		{&Manifest{}, false, false},
+		{&Manifest{ETag: `"abc"`, DataSize: 10}, true, true},
+		{&Manifest{ETag: `"abc"`, DataSize: 11}, false, true},
+		{&Manifest{ETag: `"xyz"`, Modified: "Tue, 02 Jan 2024 03:04:05 GMT", DataSize: 10}, false, true},
+		{&Manifest{Modified: "Tue, 02 Jan 2024 03:04:05 GMT", DataSize: 10}, true, true},
+		{&Manifest{Modified: "Wed, 03 Jan 2024 03:04:05 GMT", DataSize: 10}, false, true},
+	}
+
+	for i, tt := range tests {
+		same, known := v.Match(tt.m, 10)
+		if same != tt.same || known != tt.known {
+			t.Errorf("%d: got %v %v, want %v %v", i, same, known, tt.same, tt.known)
+		}
+	}
+
+}
+
+func TestStamped(t *testing.T) {
+
+	os.MkdirAll("test/", 0750)
+	defer os.RemoveAll("test/")
+
+	mod := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	paths := []string{"test/a", "test/b"}
+	for _, p := range paths {
+		os.WriteFile(p, []byte("x"), 0644)
+		os.Chtimes(p, mod, mod)
+	}
+
+	if !stamped(paths, mod) {
+		t.Errorf("want stamped")
+	}
+	if stamped(paths, mod.Add(time.Second)) || stamped(append(paths, "test/c"), mod) {
+		t.Errorf("want not stamped")
+	}
+
+}
+
+func TestCheckTimestampChanged(t *testing.T) {
+
+	os.MkdirAll("test/a", 0750)
+	os.MkdirAll("test/b", 0750)
+	defer os.RemoveAll("test/")
+
+	os.WriteFile("test/a/f_1", []byte("old"), 0644)
+	os.WriteFile("test/b/f_1", []byte("other"), 0644) //never recorded by the manifest
+
+	brs := []ByteRange{{Start: 0, End: 9}}
+	d := &Download{
+		DataSize:  10,
+		Validator: Validator{ETag: `"new"`},
+		Manifest:  &Manifest{ETag: `"old"`, DataSize: 10, Parts: []ManifestPart{{Path: "test/a/f_1", End: 9, Offset: 3}}},
+	}
+	opt := &DLOptions{BasePath: "f", DstDirs: []string{"test/a", "test/b"}}
+
+	if err := opt.checkTimestamp(d, []string{"test/a/f_1"}, brs); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !opt.ReDL[Resume] || !opt.ReDL[Completed] || d.Manifest.Parts != nil {
+		t.Errorf("a changed source was not started over")
+	}
+	if !IsFile("test/a/f_1") || !IsFile("test/b/f_1") {
+		t.Errorf("files were deleted instead of reset")
+	}
+
+}
@@ -131,7 +131,7 @@ func newReport(fios FileIOs, dataSize int64) *report {
 
 	tb := &textBlock{
 		b:      new(strings.Builder),
-		height: len(fios) + 3, //2 div plus 1 rate report
+		height: len(fios.All()) + 3, //2 div plus 1 rate report
 		width:  termWidth(),
 	}
 
@@ -192,24 +192,19 @@ func (r *report) rateReporter() func() int {
 
 func (r *report) fileReporter() func(int) int {
 
-	csize := len(r.fios)
-	cachedPartSize := make([]string, csize)
-	cachedPath := make([]string, csize)
-	cachedRuneCount := make([]int, csize)
+	rows := r.fios.All()
+	cachedPartSize := make([]string, len(rows))
 
-	for i, fio := range r.fios {
+	for i, fio := range rows {
 		ps := (fio.Scope.End - fio.Scope.Start) + 1
-		pt := fio.Path.Relative
 		cachedPartSize[i] = toEIC(ps)
-		cachedPath[i] = pt
-		cachedRuneCount[i] = utf8.RuneCountInString(pt) + 36
 	}
 
 	return func(width int) (lineCount int) {
-		for i, fio := range r.fios {
-			size, _ := fio.Size()
-			path := cachedPath[i]
-			runeCount := cachedRuneCount[i]
+		for i, fio := range rows {
+			size := fio.PullOffset()
+			path := fio.PullPath().Relative //can change on failover
+			runeCount := utf8.RuneCountInString(path) + 36
 
 			pad := 0
 			if width >= runeCount {